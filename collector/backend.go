@@ -0,0 +1,91 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+)
+
+// Statistics backends selectable with Config.Backend.
+const (
+	// BackendAuto uses the netlink backend where the kernel supports it and
+	// the ioctl backend otherwise.
+	BackendAuto = "auto"
+	// BackendNetlink reads the standardized statistics groups over the
+	// ethtool generic netlink family (Linux 5.13+), in addition to what the
+	// ioctl backend reads.
+	BackendNetlink = "netlink"
+	// BackendIoctl reads driver information and statistics with the legacy
+	// ethtool ioctl only.
+	BackendIoctl = "ioctl"
+)
+
+// errStandardStatsUnsupported is returned by StandardStats when the backend or
+// the kernel cannot report the standardized statistics groups.
+var errStandardStatsUnsupported = errors.New("standard statistics are not supported")
+
+// DriverInfoReader reads the driver information of an interface.
+// *ethtool.Ethtool implements it.
+type DriverInfoReader interface {
+	DriverInfo(intf string) (ethtool.DrvInfo, error)
+}
+
+// statsBackend reads the driver information and statistics of interfaces.
+type statsBackend interface {
+	DriverInfoReader
+	// Name returns BackendNetlink or BackendIoctl.
+	Name() string
+	// Stats returns the driver-specific counters listed by ethtool -S.
+	Stats(intf string) (map[string]uint64, error)
+	// StandardStats returns the driver-independent statistics of an
+	// interface, or errStandardStatsUnsupported.
+	StandardStats(intf string) (*standardStats, error)
+	// Close releases the resources of the backend.
+	Close() error
+}
+
+// ioctlBackend reads statistics with the legacy ethtool ioctl, which has no
+// access to the standardized statistics groups.
+type ioctlBackend struct {
+	eth *ethtool.Ethtool
+}
+
+func (b ioctlBackend) Name() string { return BackendIoctl }
+
+func (b ioctlBackend) DriverInfo(intf string) (ethtool.DrvInfo, error) {
+	return b.eth.DriverInfo(intf)
+}
+
+func (b ioctlBackend) Stats(intf string) (map[string]uint64, error) {
+	return b.eth.Stats(intf)
+}
+
+func (b ioctlBackend) StandardStats(intf string) (*standardStats, error) {
+	return nil, errStandardStatsUnsupported
+}
+
+// Close does nothing: the ethtool handle belongs to the collector.
+func (b ioctlBackend) Close() error { return nil }
+
+// newStatsBackend returns the backend selected by name, reading driver
+// information and ethtool -S counters through eth.
+func newStatsBackend(name string, eth *ethtool.Ethtool) (statsBackend, error) {
+	ioctl := ioctlBackend{eth: eth}
+
+	switch name {
+	case BackendIoctl:
+		return ioctl, nil
+	case BackendNetlink:
+		return newNetlinkBackend(ioctl)
+	case BackendAuto, "":
+		b, err := newNetlinkBackend(ioctl)
+		if err != nil {
+			log.Infof("Ethtool netlink interface unavailable, using ioctl only: %v", err)
+			return ioctl, nil
+		}
+		return b, nil
+	}
+	return nil, fmt.Errorf("unknown statistics backend %q (valid: %s, %s, %s)", name, BackendAuto, BackendNetlink, BackendIoctl)
+}
@@ -0,0 +1,96 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsCache keeps the most recent ethtool statistics of each interface so
+// that scrapes arriving within maxAge of each other share a single read.
+type statsCache struct {
+	maxAge time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*statsEntry
+}
+
+// statsEntry is the cached statistics of one interface. Its mutex is held
+// during a read, so concurrent scrapes of an interface wait for the read in
+// progress instead of issuing their own.
+type statsEntry struct {
+	mu    sync.Mutex
+	stats map[string]uint64
+	time  time.Time
+}
+
+func newStatsCache(maxAge time.Duration) *statsCache {
+	return &statsCache{
+		maxAge:  maxAge,
+		entries: make(map[string]*statsEntry),
+	}
+}
+
+// get returns the statistics of an interface and the time they were read,
+// calling read if the cached statistics are missing or older than maxAge.
+// Failed reads are not cached.
+func (s *statsCache) get(ifaceName string, read func() (map[string]uint64, error)) (map[string]uint64, time.Time, error) {
+	s.mu.Lock()
+	entry, ok := s.entries[ifaceName]
+	if !ok {
+		entry = &statsEntry{}
+		s.entries[ifaceName] = entry
+	}
+	s.mu.Unlock()
+
+	entry.mu.Lock()
+	defer entry.mu.Unlock()
+
+	if entry.stats != nil && time.Since(entry.time) < s.maxAge {
+		return entry.stats, entry.time, nil
+	}
+
+	stats, err := read()
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	entry.stats = stats
+	entry.time = time.Now()
+	return entry.stats, entry.time, nil
+}
+
+// prune forgets the interfaces that are not in names.
+func (s *statsCache) prune(names []string) {
+	current := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		current[name] = struct{}{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name := range s.entries {
+		if _, ok := current[name]; !ok {
+			delete(s.entries, name)
+		}
+	}
+}
+
+// stampMetrics forwards the metrics sent by collect to ch with an explicit
+// timestamp, so that metrics computed from cached statistics carry the time
+// the statistics were read rather than the scrape time.
+func stampMetrics(ch chan<- prometheus.Metric, ts time.Time, collect func(chan<- prometheus.Metric)) {
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+
+	go func() {
+		for m := range metrics {
+			ch <- prometheus.NewMetricWithTimestamp(ts, m)
+		}
+		close(done)
+	}()
+
+	collect(metrics)
+	close(metrics)
+	<-done
+}
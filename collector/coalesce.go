@@ -0,0 +1,183 @@
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+)
+
+// coalesceParameters lists the exported interrupt coalescing parameters by
+// their `ethtool -c` names.
+var coalesceParameters = []struct {
+	name  string
+	value func(ethtool.Coalesce) uint32
+}{
+	{"rx-usecs", func(c ethtool.Coalesce) uint32 { return c.RxCoalesceUsecs }},
+	{"rx-frames", func(c ethtool.Coalesce) uint32 { return c.RxMaxCoalescedFrames }},
+	{"rx-usecs-irq", func(c ethtool.Coalesce) uint32 { return c.RxCoalesceUsecsIrq }},
+	{"rx-frames-irq", func(c ethtool.Coalesce) uint32 { return c.RxMaxCoalescedFramesIrq }},
+	{"tx-usecs", func(c ethtool.Coalesce) uint32 { return c.TxCoalesceUsecs }},
+	{"tx-frames", func(c ethtool.Coalesce) uint32 { return c.TxMaxCoalescedFrames }},
+	{"tx-usecs-irq", func(c ethtool.Coalesce) uint32 { return c.TxCoalesceUsecsIrq }},
+	{"tx-frames-irq", func(c ethtool.Coalesce) uint32 { return c.TxMaxCoalescedFramesIrq }},
+	{"adaptive-rx", func(c ethtool.Coalesce) uint32 { return c.UseAdaptiveRxCoalesce }},
+	{"adaptive-tx", func(c ethtool.Coalesce) uint32 { return c.UseAdaptiveTxCoalesce }},
+}
+
+// CoalesceExpectation declares the expected coalesce parameters of the
+// interfaces whose names match a pattern.
+type CoalesceExpectation struct {
+	Interfaces *regexp.Regexp
+	// Values maps `ethtool -c` parameter names to their expected value.
+	// Adaptive flags use 1 for on and 0 for off.
+	Values map[string]float64
+}
+
+// expectedValue is a YAML scalar that accepts numbers and, for adaptive
+// flags, booleans.
+type expectedValue float64
+
+func (v *expectedValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Tag == "!!bool" {
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return err
+		}
+		*v = expectedValue(boolToFloat(b))
+		return nil
+	}
+	var f float64
+	if err := node.Decode(&f); err != nil {
+		return err
+	}
+	*v = expectedValue(f)
+	return nil
+}
+
+// coalesceExpectationConfig is an entry of a coalesce expectations file.
+type coalesceExpectationConfig struct {
+	// Interfaces is a regular expression matching interface names. It
+	// defaults to all interfaces.
+	Interfaces string                   `yaml:"interfaces"`
+	Coalesce   map[string]expectedValue `yaml:"coalesce"`
+}
+
+// LoadCoalesceExpectations reads expected coalesce parameters from a YAML
+// file. Entries are applied in order, so later entries override earlier ones
+// for interfaces matching both:
+//
+//	# all interfaces, except ens1f0 and ens1f1 which use fixed moderation
+//	- interfaces: '.*'
+//	  coalesce:
+//	    adaptive-rx: true
+//	- interfaces: '^ens1f[01]$'
+//	  coalesce:
+//	    adaptive-rx: false
+//	    rx-usecs: 8
+func LoadCoalesceExpectations(path string) ([]CoalesceExpectation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read coalesce expectations: %v", err)
+	}
+
+	var entries []coalesceExpectationConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&entries); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse coalesce expectations %s: %v", path, err)
+	}
+
+	return buildCoalesceExpectations(entries)
+}
+
+func buildCoalesceExpectations(entries []coalesceExpectationConfig) ([]CoalesceExpectation, error) {
+	known := make(map[string]struct{}, len(coalesceParameters))
+	names := make([]string, 0, len(coalesceParameters))
+	for _, p := range coalesceParameters {
+		known[p.name] = struct{}{}
+		names = append(names, p.name)
+	}
+
+	expectations := make([]CoalesceExpectation, 0, len(entries))
+	for i, entry := range entries {
+		pattern := entry.Interfaces
+		if pattern == "" {
+			pattern = ".*"
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: invalid interfaces pattern: %v", i+1, err)
+		}
+
+		values := make(map[string]float64, len(entry.Coalesce))
+		for name, value := range entry.Coalesce {
+			if _, ok := known[name]; !ok {
+				return nil, fmt.Errorf("entry %d: unknown coalesce parameter %q (valid: %s)", i+1, name, strings.Join(names, ", "))
+			}
+			values[name] = float64(value)
+		}
+
+		expectations = append(expectations, CoalesceExpectation{
+			Interfaces: re,
+			Values:     values,
+		})
+	}
+	return expectations, nil
+}
+
+// collectCoalesce exports the interrupt coalescing parameters of an interface
+// and, for parameters with an expected value, whether the live value drifted.
+func (c *EthtoolCollector) collectCoalesce(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	done := c.timeEthtool("coalesce")
+	coalesce, err := c.ethtool.GetCoalesce(ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get coalesce parameters for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	live := make(map[string]float64, len(coalesceParameters))
+	for _, p := range coalesceParameters {
+		value := float64(p.value(coalesce))
+		live[p.name] = value
+		c.sendGauge(ch, "coalesce_"+strings.ReplaceAll(p.name, "-", "_"),
+			"Interrupt coalescing parameter "+p.name,
+			labels, labelValues, value)
+	}
+
+	expected := make(map[string]float64)
+	for _, e := range c.options.Load().ExpectedCoalesce {
+		if !e.Interfaces.MatchString(ifaceName) {
+			continue
+		}
+		for name, value := range e.Values {
+			expected[name] = value
+		}
+	}
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	driftLabels := withLabel(labels, "section", "parameter")
+	for _, name := range names {
+		drift := live[name] != expected[name]
+		if drift {
+			log.Debugf("Coalesce parameter %s of interface %s is %v, expected %v", name, ifaceName, live[name], expected[name])
+		}
+		c.sendGauge(ch, "config_drift", "Whether a live setting differs from its expected value",
+			driftLabels, withLabel(labelValues, "coalesce", name), boolToFloat(drift))
+	}
+}
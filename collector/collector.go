@@ -1,7 +1,12 @@
 package collector
 
 import (
+	"errors"
 	"fmt"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/safchain/ethtool"
@@ -11,33 +16,180 @@ import (
 	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
 )
 
+// Config holds the optional behaviour of an EthtoolCollector.
+type Config struct {
+	// Backend selects how driver information and statistics are read:
+	// BackendAuto (the default when empty), BackendNetlink or BackendIoctl.
+	Backend string
+	// Workers is the number of interfaces collected in parallel.
+	Workers int
+	// Timeout, if positive, bounds the collection of a single interface.
+	Timeout time.Duration
+	// CacheMaxAge, if positive, lets scrapes within this age of the last
+	// read of an interface reuse its ethtool statistics.
+	CacheMaxAge time.Duration
+	// MonotonicCounters compensates ethtool counter resets and 32-bit wraps
+	// so that counters only ever increase.
+	MonotonicCounters bool
+
+	// Options holds the settings that can be changed at runtime with
+	// SetOptions.
+	Options
+
+	// SampleInterval, if positive, enables a background sampler that reads
+	// the basic counters at this interval to export rates and utilization.
+	SampleInterval time.Duration
+	// SampleWindow is the period the sampler computes rates and peak
+	// utilization over.
+	SampleWindow time.Duration
+	// MicroburstInterval, if positive, enables high-frequency sampling of
+	// the counters matching MicroburstCounters to track microbursts.
+	MicroburstInterval time.Duration
+	// MicroburstWindow is the period the highest per-interval rates are
+	// tracked over.
+	MicroburstWindow time.Duration
+	// MicroburstCounters selects the raw ethtool counters read by the
+	// microburst sampler.
+	MicroburstCounters *regexp.Regexp
+}
+
 // EthtoolCollector implements the prometheus.Collector interface.
 type EthtoolCollector struct {
-	interfaces []string
-	metrics    map[string]*prometheus.Desc
-	ethtool    *ethtool.Ethtool
+	interfaces InterfaceSource
+	config     Config
+
+	// options is the current Options. reloadMu is held for reading during
+	// scrapes and for writing while the options are replaced.
+	options  atomic.Pointer[Options]
+	reloadMu sync.RWMutex
+
+	metricsMu sync.Mutex
+	metrics   map[string]*prometheus.Desc
+	excluded  map[*prometheus.Desc]struct{} // descriptors dropped by the metric filters
+
+	inFlightMu  sync.Mutex
+	inFlight    map[string]bool // interfaces whose collection is running
+	collections sync.WaitGroup  // running collections, including timed out ones
+
+	closed   atomic.Bool // set by Close, after which the ethtool handles are gone
+	ethtool  *ethtool.Ethtool
+	backend  statsBackend
+	ioctl    *ethtoolIoctl
+	sampler  *sampler
+	bursts   *burstSampler
+	cache    *statsCache
+	counters *counterTracker
+	self     *selfMetrics
 }
 
-// NewEthtoolCollector creates a new collector for the specified interfaces.
-func NewEthtoolCollector(interfaces []string) (*EthtoolCollector, error) {
+// NewEthtoolCollector creates a new collector for the interfaces provided by
+// the given source.
+func NewEthtoolCollector(interfaces InterfaceSource, config Config) (*EthtoolCollector, error) {
 	eth, err := ethtool.NewEthtool()
 	if err != nil {
 		return nil, fmt.Errorf("failed to initialize ethtool: %v", err)
 	}
 
-	return &EthtoolCollector{
+	backend, err := newStatsBackend(config.Backend, eth)
+	if err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	ioctl, err := newEthtoolIoctl()
+	if err != nil {
+		backend.Close()
+		eth.Close()
+		return nil, err
+	}
+
+	c := &EthtoolCollector{
 		interfaces: interfaces,
+		config:     config,
 		metrics:    make(map[string]*prometheus.Desc),
+		excluded:   make(map[*prometheus.Desc]struct{}),
+		inFlight:   make(map[string]bool),
 		ethtool:    eth,
-	}, nil
+		backend:    backend,
+		ioctl:      ioctl,
+		counters:   newCounterTracker(config.MonotonicCounters),
+		self:       newSelfMetrics(),
+	}
+	options := config.Options
+	c.options.Store(&options)
+
+	if config.CacheMaxAge > 0 {
+		c.cache = newStatsCache(config.CacheMaxAge)
+	}
+
+	if config.SampleInterval > 0 {
+		if c.sampler, err = newSampler(interfaces, config.SampleInterval, config.SampleWindow); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	if config.MicroburstInterval > 0 {
+		counters := config.MicroburstCounters
+		if counters == nil {
+			counters = regexp.MustCompile(DefaultMicroburstCounters)
+		}
+		c.bursts, err = newBurstSampler(interfaces, config.MicroburstInterval, config.MicroburstWindow, counters)
+		if err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
 }
 
-// Close releases resources used by the collector.
+// Close releases resources used by the collector, returning the errors of
+// every handle that failed to close. It waits for running collections, also
+// those that timed out, to stop using the handles.
 func (c *EthtoolCollector) Close() error {
+	c.inFlightMu.Lock()
+	c.closed.Store(true)
+	c.inFlightMu.Unlock()
+	c.collections.Wait()
+
+	var errs []error
+	if c.sampler != nil {
+		errs = append(errs, c.sampler.Close())
+	}
+	if c.bursts != nil {
+		errs = append(errs, c.bursts.Close())
+	}
+	if c.backend != nil {
+		errs = append(errs, c.backend.Close())
+	}
 	if c.ethtool != nil {
 		c.ethtool.Close()
 	}
-	return nil
+	if c.ioctl != nil {
+		errs = append(errs, c.ioctl.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Ready reports whether the collector can collect metrics: its ethtool handle
+// is open and at least one interface answers driver information requests.
+func (c *EthtoolCollector) Ready() error {
+	if c.closed.Load() {
+		return fmt.Errorf("collector is closed")
+	}
+
+	names := c.interfaces.Interfaces()
+	if len(names) == 0 {
+		return fmt.Errorf("no interfaces to collect")
+	}
+	var err error
+	for _, name := range names {
+		if _, err = c.backend.DriverInfo(name); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("no collectable interface, last error: %v", err)
 }
 
 // Describe implements prometheus.Collector.
@@ -48,160 +200,417 @@ func (c *EthtoolCollector) Describe(ch chan<- *prometheus.Desc) {
 
 // Collect implements prometheus.Collector.
 func (c *EthtoolCollector) Collect(ch chan<- prometheus.Metric) {
-	for _, ifaceName := range c.interfaces {
-		// Get interface information
-		link, err := netlink.LinkByName(ifaceName)
-		if err != nil {
-			log.Errorf("Failed to get interface %s: %v", ifaceName, err)
-			continue
-		}
+	c.reloadMu.RLock()
+	defer c.reloadMu.RUnlock()
 
-		// Get NIC information and check if it's supported
-		nicInfo, err := GetNICInfo(c.ethtool, link)
-		if err != nil {
-			log.Debugf("Skipping interface %s: %v", ifaceName, err)
-			continue
-		}
+	ch, flush := c.filterMetrics(ch)
+	defer flush()
 
-		// Add driver type label
-		labels := []string{"interface", "driver"}
-		labelValues := []string{ifaceName, nicInfo.DriverType}
+	workers := c.config.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	sem := make(chan struct{}, workers)
 
-		// Collect driver-specific statistics
-		ethtoolStats, err := c.getEthtoolStats(ifaceName)
-		if err != nil {
-			log.Debugf("Failed to collect ethtool stats for interface %s: %v", ifaceName, err)
-			continue
+	names := c.interfaces.Interfaces()
+	if c.cache != nil {
+		c.cache.prune(names)
+	}
+	c.counters.prune(names)
+	c.self.prune(names)
+
+	var wg sync.WaitGroup
+	for _, ifaceName := range names {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(ifaceName string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			c.collectWithDeadline(ch, ifaceName)
+		}(ifaceName)
+	}
+	wg.Wait()
+
+	c.self.collect(ch)
+}
+
+// collectWithDeadline collects the metrics of an interface, giving up once
+// the configured timeout expires. An ethtool ioctl cannot be interrupted, so
+// a stalled collection keeps running in the background; the interface is
+// skipped until it returns, so that stalled collections do not pile up, and
+// Close waits for it.
+func (c *EthtoolCollector) collectWithDeadline(ch chan<- prometheus.Metric, ifaceName string) {
+	start := time.Now()
+	labels := []string{"interface"}
+
+	success := false
+	defer func() {
+		c.sendGauge(ch, "scrape_duration_seconds", "Duration of collecting the metrics of an interface",
+			labels, []string{ifaceName}, time.Since(start).Seconds())
+		c.sendGauge(ch, "scrape_success", "Whether collecting the metrics of an interface succeeded",
+			labels, []string{ifaceName}, boolToFloat(success))
+	}()
+
+	c.inFlightMu.Lock()
+	if c.closed.Load() {
+		c.inFlightMu.Unlock()
+		log.Debugf("Skipping interface %s: collector is closed", ifaceName)
+		return
+	}
+	if c.inFlight[ifaceName] {
+		c.inFlightMu.Unlock()
+		log.Warnf("Skipping interface %s: previous collection has not finished", ifaceName)
+		c.scrapeError(ifaceName, stageInFlight)
+		return
+	}
+	c.inFlight[ifaceName] = true
+	// Added under inFlightMu, so that Close either sees the collection or
+	// this one sees the collector closed.
+	c.collections.Add(1)
+	c.inFlightMu.Unlock()
+
+	metrics := make(chan prometheus.Metric)
+	result := make(chan error, 1)
+	var collected []prometheus.Metric
+	drained := make(chan struct{})
+
+	go func() {
+		for m := range metrics {
+			collected = append(collected, m)
 		}
+		close(drained)
+	}()
+	go func() {
+		defer c.collections.Done()
+		err := c.collectInterface(metrics, ifaceName)
+		close(metrics)
+
+		c.inFlightMu.Lock()
+		delete(c.inFlight, ifaceName)
+		c.inFlightMu.Unlock()
+
+		result <- err
+	}()
+
+	var timeout <-chan time.Time
+	if c.config.Timeout > 0 {
+		timer := time.NewTimer(c.config.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-drained:
+	case <-timeout:
+		log.Warnf("Collecting interface %s timed out after %v", ifaceName, c.config.Timeout)
+		c.scrapeError(ifaceName, stageTimeout)
+		return
+	}
+
+	for _, m := range collected {
+		ch <- m
+	}
+	success = <-result == nil
+}
+
+// collectInterface collects the metrics of a single interface.
+func (c *EthtoolCollector) collectInterface(ch chan<- prometheus.Metric, ifaceName string) error {
+	// Get interface information
+	link, err := netlink.LinkByName(ifaceName)
+	if err != nil {
+		log.Errorf("Failed to get interface %s: %v", ifaceName, err)
+		c.scrapeError(ifaceName, stageLink)
+		return err
+	}
+
+	// Get NIC information and check if it's supported
+	done := c.timeEthtool("driver_info")
+	nicInfo, err := GetNICInfo(c.backend, link)
+	done()
+	if err != nil {
+		log.Debugf("Skipping interface %s: %v", ifaceName, err)
+		c.scrapeError(ifaceName, stageDriverInfo)
+		return err
+	}
+
+	opts := c.options.Load().forDriver(nicInfo.DriverType)
 
-		// Process all statistics
-		processedStats := drivers.ProcessDriverStats(nicInfo.DriverType, ethtoolStats)
-
-		// Export basic metrics
-		basicMetrics := map[string]uint64{
-			"rx_packets": processedStats.Basic.RxPackets,
-			"rx_bytes":   processedStats.Basic.RxBytes,
-			"rx_drops":   processedStats.Basic.RxDrops,
-			"tx_packets": processedStats.Basic.TxPackets,
-			"tx_bytes":   processedStats.Basic.TxBytes,
-			"tx_drops":   processedStats.Basic.TxDrops,
+	// Add driver type label
+	labels := []string{"interface", "driver"}
+	labelValues := []string{ifaceName, nicInfo.DriverType}
+
+	// Collect driver-specific statistics
+	ethtoolStats, readAt, err := c.getEthtoolStats(ifaceName, nicInfo.DriverType)
+	if err != nil {
+		log.Debugf("Failed to collect ethtool stats for interface %s: %v", ifaceName, err)
+		c.scrapeError(ifaceName, stageStats)
+		return err
+	}
+
+	// Standardized statistics groups, which also feed the FEC metrics
+	standard := c.readStandardStats(ifaceName)
+	var standardFEC *drivers.FECStats
+	if standard != nil {
+		standardFEC = standard.FEC
+	}
+
+	// Export the metrics computed from the statistics, stamped with the
+	// time they were read if they may come from the cache
+	collectStats := func(ch chan<- prometheus.Metric) {
+		c.collectStats(ch, nicInfo.DriverType, opts, ethtoolStats, standardFEC, labels, labelValues)
+	}
+	if c.cache != nil {
+		stampMetrics(ch, readAt, collectStats)
+	} else {
+		collectStats(ch)
+	}
+	c.collectCounterResets(ch, ifaceName)
+
+	// Add standardized statistics groups
+	if standard != nil {
+		c.collectStandardStats(ch, standard, labels, labelValues)
+	}
+
+	// Add sampled rate and utilization metrics
+	if c.sampler != nil {
+		c.collectRates(ch, ifaceName, labels, labelValues)
+	}
+
+	// Add microburst metrics
+	if c.bursts != nil {
+		c.collectMicrobursts(ch, ifaceName, labels, labelValues)
+	}
+
+	// Add driver info metric
+	infoDesc := c.getOrCreateMetricDesc(
+		"info",
+		"Network interface information",
+		append(labels, "version"),
+	)
+	ch <- prometheus.MustNewConstMetric(
+		infoDesc,
+		prometheus.GaugeValue,
+		1,
+		append(labelValues, nicInfo.Version)...,
+	)
+
+	// Add link settings and state metrics
+	c.collectLinkSettings(ch, link, labels, labelValues)
+
+	// Add ring and channel configuration metrics
+	c.collectRingsAndChannels(ch, ifaceName, labels, labelValues)
+
+	// Add interrupt coalescing metrics
+	c.collectCoalesce(ch, ifaceName, labels, labelValues)
+
+	// Add FEC mode metrics
+	c.collectFECMode(ch, ifaceName, labels, labelValues)
+
+	// Add offload feature metrics
+	if opts.Features {
+		c.collectFeatures(ch, ifaceName, opts, labels, labelValues)
+	}
+
+	// Add optical transceiver metrics
+	if opts.Transceiver {
+		c.collectTransceiver(ch, ifaceName, labels, labelValues)
+	}
+
+	return nil
+}
+
+// collectStats exports the metrics computed from the ethtool statistics of an
+// interface. standardFEC holds the FEC counters read over netlink, if any.
+func (c *EthtoolCollector) collectStats(ch chan<- prometheus.Metric, driverType string, opts *Options, ethtoolStats map[string]uint64, standardFEC *drivers.FECStats, labels, labelValues []string) {
+	c.collectCounterCounts(ch, driverType, ethtoolStats, labels, labelValues)
+
+	// Process all statistics
+	processedStats := drivers.ProcessDriverStats(driverType, ethtoolStats)
+	caps := drivers.GetCapabilities(driverType)
+
+	// Export basic metrics
+	basicMetrics := map[string]uint64{
+		"rx_packets": processedStats.Basic.RxPackets,
+		"rx_bytes":   processedStats.Basic.RxBytes,
+		"rx_drops":   processedStats.Basic.RxDrops,
+		"tx_packets": processedStats.Basic.TxPackets,
+		"tx_bytes":   processedStats.Basic.TxBytes,
+		"tx_drops":   processedStats.Basic.TxDrops,
+	}
+
+	for name, value := range basicMetrics {
+		desc := c.getOrCreateMetricDesc(
+			name,
+			"Basic network interface statistic",
+			labels,
+		)
+		metric := prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(value),
+			labelValues...,
+		)
+		ch <- metric
+	}
+
+	// Export packet type metrics
+	if caps.Has(drivers.CapPacketTypes) {
+		basic := processedStats.Basic
+		c.collectPacketTypes(ch, "", "Packets by type (unicast, multicast, broadcast)",
+			packetTypeCounts{basic.RxUnicast, basic.RxMulticast, basic.RxBroadcast},
+			packetTypeCounts{basic.TxUnicast, basic.TxMulticast, basic.TxBroadcast},
+			func(counter string) bool { return drivers.ReportsPacketType(driverType, counter, false) },
+			labels, labelValues)
+	}
+
+	// Export per-queue metrics
+	queueLabels := append(labels, "queue")
+	for _, qStats := range processedStats.PerQueue {
+		queueLabelValues := append(labelValues, fmt.Sprintf("%d", qStats.QueueIndex))
+
+		queueMetrics := map[string]uint64{
+			"queue_rx_packets": qStats.RxPackets,
+			"queue_rx_bytes":   qStats.RxBytes,
+			"queue_rx_drops":   qStats.RxDrops,
+			"queue_tx_packets": qStats.TxPackets,
+			"queue_tx_bytes":   qStats.TxBytes,
+			"queue_tx_drops":   qStats.TxDrops,
 		}
 
-		for name, value := range basicMetrics {
+		for name, value := range queueMetrics {
 			desc := c.getOrCreateMetricDesc(
 				name,
-				"Basic network interface statistic",
-				labels,
+				"Per-queue network interface statistic",
+				queueLabels,
 			)
 			metric := prometheus.MustNewConstMetric(
 				desc,
 				prometheus.CounterValue,
 				float64(value),
-				labelValues...,
+				queueLabelValues...,
 			)
 			ch <- metric
 		}
+	}
+
+	// Add PHY statistics
+	if processedStats.Physical != nil {
+		phyStats := map[string]uint64{
+			"rx_bytes":      processedStats.Physical.RxBytes,
+			"tx_bytes":      processedStats.Physical.TxBytes,
+			"rx_packets":    processedStats.Physical.RxPackets,
+			"tx_packets":    processedStats.Physical.TxPackets,
+			"rx_discards":   processedStats.Physical.RxDiscarded,
+			"tx_discards":   processedStats.Physical.TxDiscarded,
+			"rx_pause_ctrl": processedStats.Physical.RxPauseCtrl,
+			"tx_pause_ctrl": processedStats.Physical.TxPauseCtrl,
+		}
+
+		for name, value := range phyStats {
+			desc := c.getOrCreateMetricDesc(
+				"phy_"+name,
+				"PHY drops for network interface",
+				labels,
+			)
+			ch <- prometheus.MustNewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(value),
+				labelValues...,
+			)
+		}
 
-		// Export per-queue metrics
-		queueLabels := append(labels, "queue")
-		for _, qStats := range processedStats.PerQueue {
-			queueLabelValues := append(labelValues, fmt.Sprintf("%d", qStats.QueueIndex))
-
-			queueMetrics := map[string]uint64{
-				"queue_rx_packets": qStats.RxPackets,
-				"queue_rx_bytes":   qStats.RxBytes,
-				"queue_rx_drops":   qStats.RxDrops,
-				"queue_tx_packets": qStats.TxPackets,
-				"queue_tx_bytes":   qStats.TxBytes,
-				"queue_tx_drops":   qStats.TxDrops,
-			}
-
-			for name, value := range queueMetrics {
-				desc := c.getOrCreateMetricDesc(
-					name,
-					"Per-queue network interface statistic",
-					queueLabels,
-				)
-				metric := prometheus.MustNewConstMetric(
-					desc,
-					prometheus.CounterValue,
-					float64(value),
-					queueLabelValues...,
-				)
-				ch <- metric
-			}
+		if caps.Has(drivers.CapPhyPacketTypes) {
+			phy := processedStats.Physical
+			c.collectPacketTypes(ch, "phy_", "Packets by type (unicast, multicast, broadcast) at physical layer",
+				packetTypeCounts{phy.RxUnicast, phy.RxMulticast, phy.RxBroadcast},
+				packetTypeCounts{phy.TxUnicast, phy.TxMulticast, phy.TxBroadcast},
+				func(counter string) bool { return drivers.ReportsPacketType(driverType, counter, true) },
+				labels, labelValues)
 		}
+	}
 
-		// Add PHY statistics
+	// Add packet size histogram
+	if len(processedStats.RxSizes) > 0 {
+		var rxBytes uint64
 		if processedStats.Physical != nil {
-			phyStats := map[string]uint64{
-				"rx_bytes":      processedStats.Physical.RxBytes,
-				"tx_bytes":      processedStats.Physical.TxBytes,
-				"rx_packets":    processedStats.Physical.RxPackets,
-				"tx_packets":    processedStats.Physical.TxPackets,
-				"rx_discards":   processedStats.Physical.RxDiscarded,
-				"tx_discards":   processedStats.Physical.TxDiscarded,
-				"rx_pause_ctrl": processedStats.Physical.RxPauseCtrl,
-				"tx_pause_ctrl": processedStats.Physical.TxPauseCtrl,
-			}
-
-			for name, value := range phyStats {
-				desc := c.getOrCreateMetricDesc(
-					"phy_"+name,
-					"PHY drops for network interface",
-					labels,
-				)
-				ch <- prometheus.MustNewConstMetric(
-					desc,
-					prometheus.CounterValue,
-					float64(value),
-					labelValues...,
-				)
-			}
+			rxBytes = processedStats.Physical.RxBytes
 		}
+		c.collectRxSizes(ch, processedStats.RxSizes, rxBytes, labels, labelValues)
+	}
 
-		// Add driver info metric
-		infoDesc := c.getOrCreateMetricDesc(
-			"info",
-			"Network interface information",
-			append(labels, "version"),
-		)
-		ch <- prometheus.MustNewConstMetric(
-			infoDesc,
-			prometheus.GaugeValue,
-			1,
-			append(labelValues, nicInfo.Version)...,
-		)
+	// Add normalized error metrics
+	if processedStats.Errors != nil {
+		c.collectErrorStats(ch, processedStats.Errors, labels, labelValues)
+	}
+
+	// Add per-priority flow control metrics
+	if len(processedStats.PerPriority) > 0 {
+		c.collectPriorityStats(ch, processedStats.PerPriority, caps, labels, labelValues)
+	}
+
+	// Add forward error correction metrics
+	var driverFEC *drivers.FECStats
+	if caps.Has(drivers.CapFEC) {
+		driverFEC = processedStats.FEC
+	}
+	if fec := mergeFECStats(driverFEC, standardFEC); fec != nil {
+		c.collectFECStats(ch, fec, labels, labelValues)
+	}
+
+	// Add raw driver-specific metrics
+	if opts.DriverStats {
+		c.collectDriverStats(ch, driverType, opts, processedStats.DriverSpecific, labels, labelValues)
 	}
 }
 
 // getOrCreateMetricDesc creates or returns an existing metric description.
 func (c *EthtoolCollector) getOrCreateMetricDesc(name, help string, labels []string) *prometheus.Desc {
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+
 	if desc, exists := c.metrics[name]; exists {
 		return desc
 	}
 
+	opts := c.options.Load()
+	fqName := prometheus.BuildFQName("nic", "", name)
 	desc := prometheus.NewDesc(
-		prometheus.BuildFQName("nic", "", name),
+		fqName,
 		help,
 		labels,
-		nil,
+		opts.constLabels(fqName, labels),
 	)
 	c.metrics[name] = desc
+	if !opts.exportsMetric(fqName) {
+		c.excluded[desc] = struct{}{}
+	}
 	return desc
 }
 
-// getEthtoolStats retrieves NIC-specific statistics using netlink ethtool interface.
-func (c *EthtoolCollector) getEthtoolStats(iface string) (map[string]uint64, error) {
-	stats, err := c.ethtool.Stats(iface)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get ethtool stats: %v", err)
+// getEthtoolStats retrieves NIC-specific statistics using netlink ethtool
+// interface, or from the cache if enabled, along with the time they were read.
+// Fresh reads go through the counter tracker.
+func (c *EthtoolCollector) getEthtoolStats(iface, driverType string) (map[string]uint64, time.Time, error) {
+	read := func() (map[string]uint64, error) {
+		done := c.timeEthtool("stats")
+		stats, err := c.backend.Stats(iface)
+		done()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get ethtool stats: %v", err)
+		}
+		return c.counters.observe(iface, driverType, stats), nil
+	}
+
+	if c.cache != nil {
+		return c.cache.get(iface, read)
 	}
-	return stats, nil
+	stats, err := read()
+	return stats, time.Now(), err
 }
 
 // GetNICInfo retrieves information about a network interface.
-func GetNICInfo(eth *ethtool.Ethtool, link netlink.Link) (*drivers.NICInfo, error) {
+func GetNICInfo(eth DriverInfoReader, link netlink.Link) (*drivers.NICInfo, error) {
 	info, err := eth.DriverInfo(link.Attrs().Name)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get driver info: %v", err)
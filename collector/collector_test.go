@@ -0,0 +1,192 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/safchain/ethtool"
+)
+
+// newTestCollector returns a collector that can build and send metrics
+// without ethtool handles.
+func newTestCollector(options Options) *EthtoolCollector {
+	c := &EthtoolCollector{
+		metrics:  make(map[string]*prometheus.Desc),
+		excluded: make(map[*prometheus.Desc]struct{}),
+		inFlight: make(map[string]bool),
+		counters: newCounterTracker(false),
+		self:     newSelfMetrics(),
+	}
+	c.options.Store(&options)
+	return c
+}
+
+// testMetric is a collected metric flattened for assertions.
+type testMetric struct {
+	name   string
+	labels map[string]string
+	value  float64
+}
+
+var fqNameRE = regexp.MustCompile(`fqName: "([^"]+)"`)
+
+// collectTestMetrics runs collect and returns the metrics it sent, sorted by
+// name.
+func collectTestMetrics(t *testing.T, collect func(ch chan<- prometheus.Metric)) []testMetric {
+	t.Helper()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		collect(ch)
+		close(ch)
+	}()
+
+	var metrics []testMetric
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatalf("failed to write metric %s: %v", m.Desc(), err)
+		}
+		match := fqNameRE.FindStringSubmatch(m.Desc().String())
+		if match == nil {
+			t.Fatalf("no name in descriptor %s", m.Desc())
+		}
+
+		tm := testMetric{name: match[1], labels: make(map[string]string)}
+		for _, label := range pb.GetLabel() {
+			tm.labels[label.GetName()] = label.GetValue()
+		}
+		switch {
+		case pb.Counter != nil:
+			tm.value = pb.GetCounter().GetValue()
+		case pb.Gauge != nil:
+			tm.value = pb.GetGauge().GetValue()
+		case pb.Histogram != nil:
+			tm.value = float64(pb.GetHistogram().GetSampleCount())
+		}
+		metrics = append(metrics, tm)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		return metrics[i].name < metrics[j].name
+	})
+	return metrics
+}
+
+// findMetric returns the first metric with the given name and labels.
+func findMetric(metrics []testMetric, name string, labels map[string]string) (testMetric, bool) {
+	for _, m := range metrics {
+		if m.name != name {
+			continue
+		}
+		matches := true
+		for k, v := range labels {
+			if m.labels[k] != v {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return m, true
+		}
+	}
+	return testMetric{}, false
+}
+
+// closeErrorBackend is a backend whose Close fails.
+type closeErrorBackend struct {
+	ioctlBackend
+	err error
+}
+
+func (b closeErrorBackend) Close() error { return b.err }
+
+func TestCloseReturnsErrors(t *testing.T) {
+	errBackend := errors.New("backend close failed")
+	c := newTestCollector(Options{})
+	c.backend = closeErrorBackend{err: errBackend}
+
+	if err := c.Close(); !errors.Is(err, errBackend) {
+		t.Errorf("Close() = %v, want %v", err, errBackend)
+	}
+	if err := c.Ready(); err == nil {
+		t.Error("Ready() of a closed collector succeeded")
+	}
+}
+
+// stalledBackend is a backend whose driver information requests block until
+// release is closed.
+type stalledBackend struct {
+	ioctlBackend
+	started  chan struct{}
+	release  chan struct{}
+	calls    atomic.Int32
+	returned atomic.Int32
+	// closedEarly records a Close while a request was still running.
+	closedEarly atomic.Bool
+}
+
+func (b *stalledBackend) DriverInfo(string) (ethtool.DrvInfo, error) {
+	if b.calls.Add(1) == 1 {
+		close(b.started)
+	}
+	<-b.release
+	b.returned.Add(1)
+	return ethtool.DrvInfo{}, errors.New("stalled")
+}
+
+func (b *stalledBackend) Close() error {
+	if b.returned.Load() != b.calls.Load() {
+		b.closedEarly.Store(true)
+	}
+	return nil
+}
+
+func TestCloseWaitsForTimedOutCollections(t *testing.T) {
+	backend := &stalledBackend{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	c := newTestCollector(Options{})
+	c.backend = backend
+	c.config.Timeout = 10 * time.Millisecond
+
+	// The loopback interface exists everywhere, so the collection reaches
+	// the backend.
+	ch := make(chan prometheus.Metric, 100)
+	c.collectWithDeadline(ch, "lo")
+	<-backend.started
+
+	closed := make(chan error, 1)
+	go func() { closed <- c.Close() }()
+	select {
+	case <-closed:
+		t.Fatal("Close() returned while a timed out collection was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(backend.release)
+	select {
+	case err := <-closed:
+		if err != nil {
+			t.Errorf("Close() = %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close() did not return after the collection finished")
+	}
+	if backend.closedEarly.Load() {
+		t.Error("backend closed before the collection stopped using it")
+	}
+
+	// Collections after Close do not reach the backend.
+	c.collectWithDeadline(ch, "lo")
+	if calls := backend.calls.Load(); calls != 1 {
+		t.Errorf("backend called %d times, want 1", calls)
+	}
+}
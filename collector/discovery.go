@@ -0,0 +1,303 @@
+package collector
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+	"golang.org/x/sys/unix"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// resubscribeDelay is how long the watcher waits before re-subscribing to
+// link updates after the netlink subscription failed.
+const resubscribeDelay = 5 * time.Second
+
+// InterfaceSource provides the set of interfaces a collector scrapes.
+type InterfaceSource interface {
+	Interfaces() []string
+}
+
+// StaticInterfaces is a fixed list of interfaces.
+type StaticInterfaces []string
+
+// Interfaces implements InterfaceSource.
+func (s StaticInterfaces) Interfaces() []string {
+	return s
+}
+
+// ReloadableInterfaces is an InterfaceSource whose underlying source can be
+// replaced while collectors and samplers use it.
+type ReloadableInterfaces struct {
+	mu     sync.RWMutex
+	source InterfaceSource
+}
+
+// NewReloadableInterfaces returns a ReloadableInterfaces providing the
+// interfaces of source.
+func NewReloadableInterfaces(source InterfaceSource) *ReloadableInterfaces {
+	return &ReloadableInterfaces{source: source}
+}
+
+// Interfaces implements InterfaceSource.
+func (r *ReloadableInterfaces) Interfaces() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.source.Interfaces()
+}
+
+// Set replaces the underlying source, closing the previous one if it is an
+// io.Closer such as an InterfaceWatcher.
+func (r *ReloadableInterfaces) Set(source InterfaceSource) {
+	r.mu.Lock()
+	previous := r.source
+	r.source = source
+	r.mu.Unlock()
+
+	if closer, ok := previous.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// Close closes the underlying source if it is an io.Closer.
+func (r *ReloadableInterfaces) Close() error {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if closer, ok := r.source.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// InterfaceFilter selects interfaces by name.
+type InterfaceFilter struct {
+	// Include, if set, limits discovery to matching interface names.
+	Include *regexp.Regexp
+	// Exclude, if set, drops matching interface names.
+	Exclude *regexp.Regexp
+}
+
+// Match reports whether an interface name passes the filter.
+func (f InterfaceFilter) Match(name string) bool {
+	if f.Include != nil && !f.Include.MatchString(name) {
+		return false
+	}
+	if f.Exclude != nil && f.Exclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// InterfaceWatcher maintains the live set of interfaces with supported
+// drivers. It follows netlink link updates (RTM_NEWLINK/RTM_DELLINK), so
+// interfaces created, renamed or removed after startup are picked up without
+// a restart.
+type InterfaceWatcher struct {
+	filter  InterfaceFilter
+	ethtool *ethtool.Ethtool
+
+	mu    sync.RWMutex
+	links map[int]string // interface index -> name
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewInterfaceWatcher lists the current interfaces and starts following link
+// updates in the background.
+func NewInterfaceWatcher(filter InterfaceFilter) (*InterfaceWatcher, error) {
+	eth, err := ethtool.NewEthtool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ethtool: %v", err)
+	}
+
+	w := &InterfaceWatcher{
+		filter:  filter,
+		ethtool: eth,
+		links:   make(map[int]string),
+		done:    make(chan struct{}),
+	}
+
+	updates, err := w.subscribe()
+	if err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	w.wg.Add(1)
+	go w.run(updates)
+
+	return w, nil
+}
+
+// Interfaces implements InterfaceSource. It returns the sorted names of the
+// currently known interfaces.
+func (w *InterfaceWatcher) Interfaces() []string {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	names := make([]string, 0, len(w.links))
+	for _, name := range w.links {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Close stops following link updates and releases the watcher's resources.
+func (w *InterfaceWatcher) Close() error {
+	close(w.done)
+	w.wg.Wait()
+	w.ethtool.Close()
+	return nil
+}
+
+// subscribe subscribes to link updates and then resynchronizes the interface
+// set from a full link dump. Subscribing first ensures no update is lost
+// between the dump and the subscription.
+func (w *InterfaceWatcher) subscribe() (<-chan netlink.LinkUpdate, error) {
+	updates := make(chan netlink.LinkUpdate, 64)
+	err := netlink.LinkSubscribeWithOptions(updates, w.done, netlink.LinkSubscribeOptions{
+		ErrorCallback: func(err error) {
+			log.Warnf("Link update subscription error: %v", err)
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to link updates: %v", err)
+	}
+
+	links, err := netlink.LinkList()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list interfaces: %v", err)
+	}
+
+	w.mu.Lock()
+	w.links = make(map[int]string)
+	w.mu.Unlock()
+	for _, link := range links {
+		w.update(link)
+	}
+
+	return updates, nil
+}
+
+// run applies link updates until the watcher is closed, re-subscribing if
+// the subscription fails.
+func (w *InterfaceWatcher) run(updates <-chan netlink.LinkUpdate) {
+	defer w.wg.Done()
+
+	for {
+		select {
+		case <-w.done:
+			return
+		case update, ok := <-updates:
+			if !ok {
+				// The subscription closes the channel when it fails.
+				if updates = w.resubscribe(); updates == nil {
+					return
+				}
+				continue
+			}
+			if update.Header.Type == unix.RTM_DELLINK {
+				w.remove(update.Link)
+				continue
+			}
+			w.update(update.Link)
+		}
+	}
+}
+
+// resubscribe retries the subscription until it succeeds or the watcher is
+// closed, in which case it returns nil.
+func (w *InterfaceWatcher) resubscribe() <-chan netlink.LinkUpdate {
+	for {
+		select {
+		case <-w.done:
+			return nil
+		case <-time.After(resubscribeDelay):
+		}
+
+		updates, err := w.subscribe()
+		if err == nil {
+			return updates
+		}
+		log.Errorf("Failed to re-subscribe to link updates: %v", err)
+	}
+}
+
+// update adds, renames or drops an interface after a link change.
+func (w *InterfaceWatcher) update(link netlink.Link) {
+	attrs := link.Attrs()
+
+	w.mu.RLock()
+	current, known := w.links[attrs.Index]
+	w.mu.RUnlock()
+	if known && current == attrs.Name {
+		return
+	}
+
+	driver, ok := w.accept(attrs.Name)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !ok {
+		if known {
+			delete(w.links, attrs.Index)
+			log.Infof("Stopped monitoring interface %s (renamed to %s)", current, attrs.Name)
+		}
+		return
+	}
+
+	w.links[attrs.Index] = attrs.Name
+	if known {
+		log.Infof("Interface %s renamed to %s", current, attrs.Name)
+	} else {
+		log.Infof("Monitoring interface %s with driver %s", attrs.Name, driver)
+	}
+}
+
+// remove drops a deleted interface.
+func (w *InterfaceWatcher) remove(link netlink.Link) {
+	attrs := link.Attrs()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if name, known := w.links[attrs.Index]; known {
+		delete(w.links, attrs.Index)
+		log.Infof("Interface %s removed", name)
+	}
+}
+
+// accept reports whether an interface should be monitored, returning its driver.
+func (w *InterfaceWatcher) accept(name string) (string, bool) {
+	if name == "lo" || !w.filter.Match(name) {
+		return "", false
+	}
+	return supportedInterfaceDriver(w.ethtool, name)
+}
+
+// supportedInterfaceDriver returns the driver of an interface if it is
+// supported and exposes ethtool statistics.
+func supportedInterfaceDriver(eth *ethtool.Ethtool, name string) (string, bool) {
+	info, err := eth.DriverInfo(name)
+	if err != nil {
+		log.Debugf("Failed to get driver info for interface %s: %v", name, err)
+		return "", false
+	}
+	if info.NStats == 0 {
+		log.Debugf("Skipping interface %s with driver %s: no ethtool statistics", name, info.Driver)
+		return "", false
+	}
+	if !drivers.IsSupportedDriver(info.Driver) {
+		log.Debugf("Skipping unsupported interface %s with driver %s", name, info.Driver)
+		return "", false
+	}
+	return info.Driver, true
+}
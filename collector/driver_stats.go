@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// rawStatPrefix is the key prefix drivers use for raw counters in
+// ProcessedStats.DriverSpecific.
+const rawStatPrefix = "raw_"
+
+// collectDriverStats exports the raw driver-specific counters of an interface
+// as nic_<driverprefix>_<sanitized_name> counters. The raw counter name is kept
+// in the "counter" label, so counters whose names sanitize to the same metric
+// name, e.g. "rx-0.packets" and "rx_0_packets", remain distinct series with
+// the same meaning on every interface.
+func (c *EthtoolCollector) collectDriverStats(ch chan<- prometheus.Metric, driverType string, opts *Options, driverSpecific map[string]uint64, labels, labelValues []string) {
+	prefix := drivers.GetMetricPrefix(driverType)
+	counterLabels := withLabel(labels, "counter")
+	for key, value := range driverSpecific {
+		name := strings.TrimPrefix(key, rawStatPrefix)
+		if !includeDriverStat(opts, name) {
+			continue
+		}
+
+		desc := c.getOrCreateMetricDesc(
+			driverMetricName(prefix, name),
+			"Driver-specific ethtool statistic",
+			counterLabels,
+		)
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(value),
+			withLabel(labelValues, name)...,
+		)
+	}
+}
+
+// includeDriverStat applies the configured include/exclude filters to a raw
+// ethtool counter name.
+func includeDriverStat(opts *Options, name string) bool {
+	if opts.DriverStatsInclude != nil && !opts.DriverStatsInclude.MatchString(name) {
+		return false
+	}
+	if opts.DriverStatsExclude != nil && opts.DriverStatsExclude.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// driverMetricName returns the metric name (without the "nic_" namespace) of
+// a raw ethtool counter.
+func driverMetricName(prefix, name string) string {
+	return prefix + "_" + sanitizeMetricName(name)
+}
+
+// sanitizeMetricName turns an ethtool counter name into a valid Prometheus
+// metric name fragment. Dots, dashes and any other invalid characters become
+// underscores, and runs of underscores are collapsed.
+func sanitizeMetricName(name string) string {
+	var b strings.Builder
+	b.Grow(len(name))
+
+	lastUnderscore := false
+	for _, r := range name {
+		valid := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !valid {
+			if !lastUnderscore {
+				b.WriteByte('_')
+			}
+			lastUnderscore = true
+			continue
+		}
+		b.WriteRune(r)
+		lastUnderscore = false
+	}
+
+	return strings.Trim(b.String(), "_")
+}
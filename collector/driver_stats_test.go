@@ -0,0 +1,88 @@
+package collector
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSanitizeMetricName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"rx_packets", "rx_packets"},
+		{"rx_crc_errors.nic", "rx_crc_errors_nic"},
+		{"rx-0.packets", "rx_0_packets"},
+		{"tx_queue_0_bytes", "tx_queue_0_bytes"},
+		{"[0]: rx_ucast_packets", "0_rx_ucast_packets"},
+		{"rx__-__drops", "rx_drops"},
+		{"__leading", "leading"},
+		{"trailing--", "trailing"},
+		{"port.rx_size_64", "port_rx_size_64"},
+	}
+	for _, tt := range tests {
+		if got := sanitizeMetricName(tt.name); got != tt.want {
+			t.Errorf("sanitizeMetricName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCollectDriverStatsKeepsRawName(t *testing.T) {
+	c := newTestCollector(Options{})
+	labels := []string{"interface", "driver"}
+
+	collect := func(iface string, stats map[string]uint64) []testMetric {
+		return collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+			c.collectDriverStats(ch, "ice", &Options{}, stats, labels, []string{iface, "ice"})
+		})
+	}
+
+	// Both counters sanitize to the same name and must stay separate series.
+	first := collect("eth0", map[string]uint64{
+		"raw_rx-0.packets": 1,
+		"raw_rx_0_packets": 2,
+	})
+	if len(first) != 2 {
+		t.Fatalf("got %d metrics, want 2", len(first))
+	}
+	for counter, want := range map[string]float64{"rx-0.packets": 1, "rx_0_packets": 2} {
+		m, ok := findMetric(first, "nic_ice_rx_0_packets", map[string]string{"counter": counter})
+		if !ok || m.value != want {
+			t.Errorf("counter %s: got %+v (found %v), want value %v", counter, m, ok, want)
+		}
+	}
+
+	// An interface with only one of them maps it to the same series.
+	second := collect("eth1", map[string]uint64{"raw_rx_0_packets": 5})
+	if _, ok := findMetric(second, "nic_ice_rx_0_packets", map[string]string{"counter": "rx_0_packets"}); !ok {
+		t.Errorf("rx_0_packets of eth1 not exported with its raw name: %+v", second)
+	}
+}
+
+func TestCollectDriverStatsFilters(t *testing.T) {
+	c := newTestCollector(Options{})
+	opts := &Options{
+		DriverStatsInclude: regexp.MustCompile(`crc|cqe`),
+		DriverStatsExclude: regexp.MustCompile(`^tx_`),
+	}
+	stats := map[string]uint64{
+		"raw_rx_crc_errors": 1,
+		"raw_tx_crc_errors": 2,
+		"raw_rx_cqe_err":    3,
+		"raw_rx_packets":    4,
+	}
+
+	metrics := collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.collectDriverStats(ch, "mlx5_core", opts, stats, []string{"interface"}, []string{"eth0"})
+	})
+
+	var got []string
+	for _, m := range metrics {
+		got = append(got, m.labels["counter"])
+	}
+	if len(got) != 2 || got[0] != "rx_cqe_err" || got[1] != "rx_crc_errors" {
+		t.Errorf("exported counters = %v, want [rx_cqe_err rx_crc_errors]", got)
+	}
+}
@@ -1,5 +1,7 @@
 package drivers
 
+import "strings"
+
 // Known NIC drivers
 const (
 	DriverMLX5  = "mlx5_core"
@@ -8,22 +10,34 @@ const (
 	DriverIXGBE = "ixgbe"
 )
 
-var supportedDrivers = map[string]struct{}{
-	DriverMLX5:  {},
-	DriverI40E:  {},
-	DriverICE:   {},
-	DriverIXGBE: {},
-}
-
 // IsSupportedDriver reports whether this exporter accepts a driver.
 func IsSupportedDriver(driver string) bool {
-	_, ok := supportedDrivers[driver]
+	_, ok := Lookup(driver)
 	return ok
 }
 
 // SupportedDriversString returns a human-readable supported-driver list.
 func SupportedDriversString() string {
-	return "mlx5, i40e, ice, and ixgbe"
+	names := RegisteredDrivers()
+	prefixes := make([]string, 0, len(names)+1)
+	for _, name := range names {
+		if d, ok := Lookup(name); ok {
+			prefixes = append(prefixes, d.Prefix())
+		}
+	}
+	if genericFallback {
+		prefixes = append(prefixes, "generic fallback")
+	}
+
+	switch len(prefixes) {
+	case 0:
+		return "no"
+	case 1:
+		return prefixes[0]
+	case 2:
+		return prefixes[0] + " and " + prefixes[1]
+	}
+	return strings.Join(prefixes[:len(prefixes)-1], ", ") + ", and " + prefixes[len(prefixes)-1]
 }
 
 // BasicStats contains the basic metrics every NIC should provide
@@ -34,6 +48,13 @@ type BasicStats struct {
 	TxPackets uint64 // Total transmitted packets
 	TxBytes   uint64 // Total transmitted bytes
 	TxDrops   uint64 // Total dropped TX packets
+
+	RxUnicast   uint64 // Received unicast packets
+	RxMulticast uint64 // Received multicast packets
+	RxBroadcast uint64 // Received broadcast packets
+	TxUnicast   uint64 // Transmitted unicast packets
+	TxMulticast uint64 // Transmitted multicast packets
+	TxBroadcast uint64 // Transmitted broadcast packets
 }
 
 // PhyStats contains physical layer statistics
@@ -46,6 +67,12 @@ type PhyStats struct {
 	TxDiscarded uint64 // Packets discarded at physical layer
 	RxPauseCtrl uint64 // Received pause control frames
 	TxPauseCtrl uint64 // Transmitted pause control frames
+	RxUnicast   uint64 // Unicast packets received at physical layer
+	RxMulticast uint64 // Multicast packets received at physical layer
+	RxBroadcast uint64 // Broadcast packets received at physical layer
+	TxUnicast   uint64 // Unicast packets transmitted at physical layer
+	TxMulticast uint64 // Multicast packets transmitted at physical layer
+	TxBroadcast uint64 // Broadcast packets transmitted at physical layer
 }
 
 // QueueStats contains per-queue statistics
@@ -59,11 +86,81 @@ type QueueStats struct {
 	TxDrops    uint64
 }
 
+// Normalized error reasons of ErrorStats
+const (
+	ErrorCRC              = "crc"
+	ErrorLength           = "length"
+	ErrorAlignment        = "alignment"
+	ErrorOversize         = "oversize"
+	ErrorUndersize        = "undersize"
+	ErrorFragments        = "fragments"
+	ErrorJabber           = "jabber"
+	ErrorBufferExhaustion = "buffer_exhaustion"
+	ErrorDMA              = "dma"
+	ErrorLinkDown         = "link_down"
+)
+
+// ErrorReasons lists the normalized error reasons.
+var ErrorReasons = []string{
+	ErrorCRC,
+	ErrorLength,
+	ErrorAlignment,
+	ErrorOversize,
+	ErrorUndersize,
+	ErrorFragments,
+	ErrorJabber,
+	ErrorBufferExhaustion,
+	ErrorDMA,
+	ErrorLinkDown,
+}
+
+// ErrorStats contains errored and dropped packets by normalized reason
+type ErrorStats struct {
+	Rx map[string]uint64 // Receive errors by reason, absent if not reported by the driver
+	Tx map[string]uint64 // Transmit errors by reason, absent if not reported by the driver
+}
+
+// PriorityStats contains per-priority flow control (PFC) statistics
+type PriorityStats struct {
+	Priority           int
+	RxPauseFrames      uint64 // Received pause frames (XON and XOFF)
+	TxPauseFrames      uint64 // Transmitted pause frames (XON and XOFF)
+	RxPauseDuration    uint64 // Time paused by received pause frames, in microseconds
+	TxPauseDuration    uint64 // Time paused by transmitted pause frames, in microseconds
+	RxPauseTransitions uint64 // Received transitions from XON to XOFF
+	TxPauseTransitions uint64 // Transmitted transitions from XON to XOFF
+}
+
+// FEC counters of FECStats
+const (
+	FECCorrectedBlocks     = "corrected_blocks"     // Codewords with errors corrected by FEC
+	FECUncorrectableBlocks = "uncorrectable_blocks" // Codewords with errors FEC could not correct
+	FECCorrectedBits       = "corrected_bits"       // Bit errors corrected by FEC (pre-FEC bit errors)
+	FECReceivedBits        = "received_bits"        // Bits received, the denominator of the pre-FEC bit error rate
+)
+
+// FECStats contains forward error correction statistics
+type FECStats struct {
+	Total map[string]uint64         // FEC counters, absent if not reported by the driver
+	Lanes map[int]map[string]uint64 // FEC counters by lane, absent if not reported by the driver
+}
+
+// SizeBucket counts packets by size. A bucket holds the packets larger than
+// the upper bound of the previous bucket, up to and including its own.
+type SizeBucket struct {
+	UpperBound float64 // Upper bound in bytes, +Inf for the last bucket of some drivers
+	Count      uint64
+}
+
 // ProcessedStats contains both basic and driver-specific metrics
 type ProcessedStats struct {
 	Basic          BasicStats
 	Physical       *PhyStats // Physical layer statistics, may be nil if not supported
 	PerQueue       []QueueStats
+	PerPriority    []PriorityStats // Per-priority flow control statistics, may be empty if not supported
+	Errors         *ErrorStats     // Error statistics, may be nil if not supported
+	RxSizes        []SizeBucket    // Received packet size (RMON) buckets by ascending bound, may be empty if not supported
+	FEC            *FECStats       // Forward error correction statistics, may be nil if not supported
 	DriverSpecific map[string]uint64
 }
 
@@ -77,31 +174,58 @@ type NICInfo struct {
 
 // GetMetricPrefix returns the metric prefix for a driver type
 func GetMetricPrefix(driverType string) string {
-	switch driverType {
-	case DriverMLX5:
-		return "mlx5"
-	case DriverI40E:
-		return "i40e"
-	case DriverICE:
-		return "ice"
-	case DriverIXGBE:
-		return "ixgbe"
-	default:
-		return "unknown"
+	if d, ok := Lookup(driverType); ok {
+		return d.Prefix()
+	}
+	return "unknown"
+}
+
+// GetCapabilities returns the optional statistics a driver type provides
+func GetCapabilities(driverType string) Capability {
+	if d, ok := Lookup(driverType); ok {
+		return d.Capabilities()
+	}
+	return 0
+}
+
+// UnmappedCounters returns how many raw counters a driver type does not turn
+// into standard statistics. It reports false if the driver cannot tell.
+func UnmappedCounters(driverType string, rawStats map[string]uint64) (int, bool) {
+	d, ok := Lookup(driverType)
+	if !ok {
+		return 0, false
+	}
+	mapper, ok := d.(CounterMapper)
+	if !ok {
+		return 0, false
+	}
+
+	var unmapped int
+	for name := range rawStats {
+		if !mapper.Maps(name) {
+			unmapped++
+		}
+	}
+	return unmapped, true
+}
+
+// ReportsPacketType reports whether a driver type fills a packet type counter
+// (rx_unicast, tx_broadcast, ...); see PacketTypeReporter.
+func ReportsPacketType(driverType, counter string, physical bool) bool {
+	d, ok := Lookup(driverType)
+	if !ok {
+		return false
+	}
+	if reporter, ok := d.(PacketTypeReporter); ok {
+		return reporter.ReportsPacketType(counter, physical)
 	}
+	return true
 }
 
 // ProcessDriverStats processes driver-specific statistics
 func ProcessDriverStats(driverType string, rawStats map[string]uint64) ProcessedStats {
-	switch driverType {
-	case DriverMLX5:
-		return processMLX5Stats(rawStats)
-	case DriverI40E:
-		return processI40EStats(rawStats)
-	case DriverICE:
-		return processICEStats(rawStats)
-	case DriverIXGBE:
-		return processIXGBEStats(rawStats)
+	if d, ok := Lookup(driverType); ok {
+		return d.Process(rawStats)
 	}
 
 	return ProcessedStats{
@@ -0,0 +1,195 @@
+package drivers
+
+import (
+	"regexp"
+	"strconv"
+)
+
+// genericFallback controls whether drivers without a dedicated processor are
+// accepted and handled by the generic processor.
+var genericFallback bool
+
+// SetGenericFallback enables or disables the generic fallback processor for
+// drivers that are not explicitly supported.
+func SetGenericFallback(enabled bool) {
+	genericFallback = enabled
+}
+
+// GenericFallbackEnabled reports whether the generic fallback processor is enabled.
+func GenericFallbackEnabled() bool {
+	return genericFallback
+}
+
+// GenericMetricMapping lists, for each basic metric, common counter names used
+// by drivers such as virtio_net, ena, bnxt_en and igb. The first name present
+// in the raw statistics is used; the candidates are alternatives, not addends.
+var GenericMetricMapping = map[string][]string{
+	CounterRxPackets: {"rx_packets", "rx_good_frames", "rx_total_packets"},
+	CounterRxBytes:   {"rx_bytes", "rx_good_bytes", "rx_total_bytes"},
+	CounterRxDrops:   {"rx_dropped", "rx_drops", "rx_discards"},
+	CounterTxPackets: {"tx_packets", "tx_good_frames", "tx_total_packets"},
+	CounterTxBytes:   {"tx_bytes", "tx_good_bytes", "tx_total_bytes"},
+	CounterTxDrops:   {"tx_dropped", "tx_drops", "tx_discards"},
+}
+
+// Generic per-queue counter patterns. Each pattern captures the direction, the
+// queue index and the counter, e.g. "rx_queue_0_packets" (virtio_net, igb),
+// "rx-0.packets" (i40e style) and "queue_0_rx_cnt" (ena).
+var genericQueuePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^(?P<dir>rx|tx)_queue_(?P<queue>\d+)_(?P<counter>packets|bytes|drops|dropped)$`),
+	regexp.MustCompile(`^(?P<dir>rx|tx)-(?P<queue>\d+)\.(?P<counter>packets|bytes|drops|dropped)$`),
+	regexp.MustCompile(`^queue_(?P<queue>\d+)_(?P<dir>rx|tx)_(?P<counter>cnt|bytes|drops|dropped)$`),
+}
+
+func processGenericBasicStats(rawStats map[string]uint64, stats *BasicStats) {
+	for basicMetric, candidates := range GenericMetricMapping {
+		total, ok := firstMetric(rawStats, candidates)
+		if !ok {
+			continue
+		}
+
+		switch basicMetric {
+		case CounterRxPackets:
+			stats.RxPackets = total
+		case CounterRxBytes:
+			stats.RxBytes = total
+		case CounterRxDrops:
+			stats.RxDrops = total
+		case CounterTxPackets:
+			stats.TxPackets = total
+		case CounterTxBytes:
+			stats.TxBytes = total
+		case CounterTxDrops:
+			stats.TxDrops = total
+		}
+	}
+}
+
+func processGenericQueueStats(rawStats map[string]uint64) []QueueStats {
+	queueMap := make(map[int]*QueueStats)
+
+	for name, value := range rawStats {
+		for _, pattern := range genericQueuePatterns {
+			matches := pattern.FindStringSubmatch(name)
+			if matches == nil {
+				continue
+			}
+
+			qIndex, err := strconv.Atoi(matches[pattern.SubexpIndex("queue")])
+			if err != nil {
+				break
+			}
+
+			qStats, exists := queueMap[qIndex]
+			if !exists {
+				qStats = &QueueStats{QueueIndex: qIndex}
+				queueMap[qIndex] = qStats
+			}
+
+			direction := matches[pattern.SubexpIndex("dir")]
+			switch matches[pattern.SubexpIndex("counter")] {
+			case "packets", "cnt":
+				if direction == "rx" {
+					qStats.RxPackets = value
+				} else {
+					qStats.TxPackets = value
+				}
+			case "bytes":
+				if direction == "rx" {
+					qStats.RxBytes = value
+				} else {
+					qStats.TxBytes = value
+				}
+			case "drops", "dropped":
+				if direction == "rx" {
+					qStats.RxDrops += value
+				} else {
+					qStats.TxDrops += value
+				}
+			}
+			break
+		}
+	}
+
+	result := make([]QueueStats, 0, len(queueMap))
+	for _, stats := range queueMap {
+		result = append(result, *stats)
+	}
+
+	return result
+}
+
+func processGenericStats(rawStats map[string]uint64) ProcessedStats {
+	result := ProcessedStats{
+		DriverSpecific: make(map[string]uint64),
+	}
+
+	processGenericBasicStats(rawStats, &result.Basic)
+
+	// There is no portable naming for physical layer counters, so Physical
+	// stays nil for generic drivers.
+	result.PerQueue = processGenericQueueStats(rawStats)
+
+	for name, value := range rawStats {
+		result.DriverSpecific["raw_"+name] = value
+	}
+
+	return result
+}
+
+// genericDriver processes statistics of a driver without a dedicated
+// processor. It is returned by Lookup when the generic fallback is enabled.
+type genericDriver struct {
+	name string
+}
+
+func (d genericDriver) Name() string             { return d.name }
+func (d genericDriver) Prefix() string           { return genericMetricPrefix(d.name) }
+func (d genericDriver) Capabilities() Capability { return CapPerQueue }
+
+// Maps reports whether a counter is a basic metric candidate or a per-queue
+// counter. Candidates that lose to an earlier alternative still count as
+// mapped.
+func (d genericDriver) Maps(counter string) bool {
+	for _, candidates := range GenericMetricMapping {
+		for _, name := range candidates {
+			if name == counter {
+				return true
+			}
+		}
+	}
+	for _, pattern := range genericQueuePatterns {
+		if pattern.MatchString(counter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (d genericDriver) Process(rawStats map[string]uint64) ProcessedStats {
+	return processGenericStats(rawStats)
+}
+
+// genericMetricPrefix derives a metric prefix from a driver name, e.g.
+// "virtio_net" or "bnxt_en".
+func genericMetricPrefix(driver string) string {
+	prefix := []byte(driver)
+	for i, c := range prefix {
+		if (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			prefix[i] = '_'
+		}
+	}
+	if len(prefix) == 0 {
+		return "unknown"
+	}
+	return string(prefix)
+}
+
+func firstMetric(stats map[string]uint64, names []string) (uint64, bool) {
+	for _, name := range names {
+		if value, ok := stats[name]; ok {
+			return value, true
+		}
+	}
+	return 0, false
+}
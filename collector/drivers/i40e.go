@@ -1,9 +1,21 @@
 package drivers
 
-import (
-	"regexp"
-	"strconv"
-)
+func init() {
+	Register(MustNewMappingDriver(DriverI40E, "i40e", &Mapping{
+		Basic:   I40EMetricMapping,
+		Phy:     I40EPhyMetricMapping,
+		Errors:  I40EErrorMetricMapping,
+		RxSizes: I40ERxSizeMetricMapping,
+		Queue: &QueueMapping{
+			Pattern:  i40eQueuePattern,
+			Counters: I40EQueueMetricMapping,
+		},
+		PFC: &PriorityMapping{
+			Pattern:  i40ePriorityPattern,
+			Counters: I40EPriorityMetricMapping,
+		},
+	}))
+}
 
 // I40EMetricMapping defines which source metrics contribute to each basic metric.
 var I40EMetricMapping = map[string][]string{
@@ -13,6 +25,13 @@ var I40EMetricMapping = map[string][]string{
 	CounterTxBytes:   {"tx_bytes"},
 	CounterRxDrops:   {"rx_dropped", "rx_missed_errors"},
 	CounterTxDrops:   {"tx_dropped"},
+
+	CounterRxUnicast:   {"rx_unicast"},
+	CounterRxMulticast: {"rx_multicast"},
+	CounterRxBroadcast: {"rx_broadcast"},
+	CounterTxUnicast:   {"tx_unicast"},
+	CounterTxMulticast: {"tx_multicast"},
+	CounterTxBroadcast: {"tx_broadcast"},
 }
 
 // I40EPhyMetricMapping defines which source metrics contribute to each physical metric.
@@ -25,119 +44,52 @@ var I40EPhyMetricMapping = map[string][]string{
 	CounterTxDiscards:  {"port.tx_dropped_link_down"},
 	CounterRxPauseCtrl: {"port.link_xon_rx", "port.link_xoff_rx"},
 	CounterTxPauseCtrl: {"port.link_xon_tx", "port.link_xoff_tx"},
-}
-
-var i40eQueuePattern = regexp.MustCompile(`^(rx|tx)-(\d+)\.(packets|bytes)$`)
-
-func processI40EBasicStats(rawStats map[string]uint64, stats *BasicStats) {
-	for basicMetric, sourceMetrics := range I40EMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
 
-		switch basicMetric {
-		case CounterRxPackets:
-			stats.RxPackets = total
-		case CounterRxBytes:
-			stats.RxBytes = total
-		case CounterTxPackets:
-			stats.TxPackets = total
-		case CounterTxBytes:
-			stats.TxBytes = total
-		case CounterRxDrops:
-			stats.RxDrops = total
-		case CounterTxDrops:
-			stats.TxDrops = total
-		}
-	}
+	CounterRxUnicast:   {"port.rx_unicast"},
+	CounterRxMulticast: {"port.rx_multicast"},
+	CounterRxBroadcast: {"port.rx_broadcast"},
+	CounterTxUnicast:   {"port.tx_unicast"},
+	CounterTxMulticast: {"port.tx_multicast"},
+	CounterTxBroadcast: {"port.tx_broadcast"},
 }
 
-func processI40EPhyStats(rawStats map[string]uint64, phyStats *PhyStats) {
-	for phyMetric, sourceMetrics := range I40EPhyMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
-
-		switch phyMetric {
-		case CounterRxPackets:
-			phyStats.RxPackets = total
-		case CounterRxBytes:
-			phyStats.RxBytes = total
-		case CounterTxPackets:
-			phyStats.TxPackets = total
-		case CounterTxBytes:
-			phyStats.TxBytes = total
-		case CounterRxDiscards:
-			phyStats.RxDiscarded = total
-		case CounterTxDiscards:
-			phyStats.TxDiscarded = total
-		case CounterRxPauseCtrl:
-			phyStats.RxPauseCtrl = total
-		case CounterTxPauseCtrl:
-			phyStats.TxPauseCtrl = total
-		}
-	}
+// I40EQueueMetricMapping defines which queue counters contribute to each queue metric.
+var I40EQueueMetricMapping = map[string][]string{
+	CounterRxPackets: {"rx_packets"},
+	CounterRxBytes:   {"rx_bytes"},
+	CounterTxPackets: {"tx_packets"},
+	CounterTxBytes:   {"tx_bytes"},
 }
 
-func processI40EQueueStats(rawStats map[string]uint64) []QueueStats {
-	queueMap := make(map[int]*QueueStats)
+const i40eQueuePattern = `^(?P<direction>rx|tx)-(?P<queue>\d+)\.(?P<counter>packets|bytes)$`
 
-	for name, value := range rawStats {
-		matches := i40eQueuePattern.FindStringSubmatch(name)
-		if matches == nil {
-			continue
-		}
-
-		qIndex, err := strconv.Atoi(matches[2])
-		if err != nil {
-			continue
-		}
-
-		qStats, exists := queueMap[qIndex]
-		if !exists {
-			qStats = &QueueStats{QueueIndex: qIndex}
-			queueMap[qIndex] = qStats
-		}
-
-		switch {
-		case matches[1] == "rx" && matches[3] == "packets":
-			qStats.RxPackets = value
-		case matches[1] == "rx" && matches[3] == "bytes":
-			qStats.RxBytes = value
-		case matches[1] == "tx" && matches[3] == "packets":
-			qStats.TxPackets = value
-		case matches[1] == "tx" && matches[3] == "bytes":
-			qStats.TxBytes = value
-		}
-	}
-
-	result := make([]QueueStats, 0, len(queueMap))
-	for _, stats := range queueMap {
-		result = append(result, *stats)
-	}
-
-	return result
+// I40EPriorityMetricMapping defines which per-priority counters contribute to each PFC metric.
+var I40EPriorityMetricMapping = map[string][]string{
+	CounterPauseFrames:      {"rx_xon_rx", "rx_xoff_rx", "tx_xon_tx", "tx_xoff_tx"},
+	CounterPauseTransitions: {"rx_xon_2_xoff"},
 }
 
-func processI40EStats(rawStats map[string]uint64) ProcessedStats {
-	result := ProcessedStats{
-		DriverSpecific: make(map[string]uint64),
-	}
-
-	processI40EBasicStats(rawStats, &result.Basic)
-
-	result.Physical = &PhyStats{}
-	processI40EPhyStats(rawStats, result.Physical)
-
-	result.PerQueue = processI40EQueueStats(rawStats)
-
-	for name, value := range rawStats {
-		result.DriverSpecific["raw_"+name] = value
-	}
-
-	return result
+const i40ePriorityPattern = `^port\.(?P<direction>rx|tx)_priority_(?P<priority>\d)_(?P<counter>xon_rx|xoff_rx|xon_tx|xoff_tx|xon_2_xoff)$`
+
+// I40EErrorMetricMapping defines which source metrics contribute to each error category.
+var I40EErrorMetricMapping = map[string][]string{
+	"rx_" + ErrorCRC:              {"port.rx_crc_errors"},
+	"rx_" + ErrorLength:           {"port.rx_length_errors"},
+	"rx_" + ErrorOversize:         {"port.rx_oversize"},
+	"rx_" + ErrorUndersize:        {"port.rx_undersize"},
+	"rx_" + ErrorFragments:        {"port.rx_fragments"},
+	"rx_" + ErrorJabber:           {"port.rx_jabber"},
+	"rx_" + ErrorBufferExhaustion: {"rx_alloc_fail", "rx_pg_alloc_fail"},
+	"tx_" + ErrorLinkDown:         {"port.tx_dropped_link_down"},
 }
 
-func sumMetrics(stats map[string]uint64, names []string) uint64 {
-	var total uint64
-	for _, name := range names {
-		total += stats[name]
-	}
-	return total
+// I40ERxSizeMetricMapping defines which source metrics contribute to each received packet size bucket.
+var I40ERxSizeMetricMapping = map[string][]string{
+	"64":   {"port.rx_size_64"},
+	"127":  {"port.rx_size_127"},
+	"255":  {"port.rx_size_255"},
+	"511":  {"port.rx_size_511"},
+	"1023": {"port.rx_size_1023"},
+	"1522": {"port.rx_size_1522"},
+	"+Inf": {"port.rx_size_big"},
 }
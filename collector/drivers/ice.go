@@ -1,9 +1,24 @@
 package drivers
 
-import (
-	"regexp"
-	"strconv"
-)
+func init() {
+	Register(MustNewMappingDriver(DriverICE, "ice", &Mapping{
+		Basic:   ICEMetricMapping,
+		Phy:     ICEPhyMetricMapping,
+		Errors:  ICEErrorMetricMapping,
+		RxSizes: ICERxSizeMetricMapping,
+		Queue: &QueueMapping{
+			Pattern:  iceQueuePattern,
+			Counters: ICEQueueMetricMapping,
+		},
+		PFC: &PriorityMapping{
+			Pattern:  icePriorityPattern,
+			Counters: ICEPriorityMetricMapping,
+		},
+		FEC: &FECMapping{
+			Counters: ICEFECMetricMapping,
+		},
+	}))
+}
 
 // ICEMetricMapping defines which source metrics contribute to each basic metric.
 var ICEMetricMapping = map[string][]string{
@@ -13,6 +28,13 @@ var ICEMetricMapping = map[string][]string{
 	CounterTxPackets: {"tx_unicast", "tx_multicast", "tx_broadcast"},
 	CounterTxBytes:   {"tx_bytes"},
 	CounterTxDrops:   {"tx_errors", "tx_dropped_link_down.nic"},
+
+	CounterRxUnicast:   {"rx_unicast"},
+	CounterRxMulticast: {"rx_multicast"},
+	CounterRxBroadcast: {"rx_broadcast"},
+	CounterTxUnicast:   {"tx_unicast"},
+	CounterTxMulticast: {"tx_multicast"},
+	CounterTxBroadcast: {"tx_broadcast"},
 }
 
 // ICEPhyMetricMapping defines which source metrics contribute to each physical metric.
@@ -25,111 +47,57 @@ var ICEPhyMetricMapping = map[string][]string{
 	CounterTxDiscards:  {"tx_dropped_link_down.nic"},
 	CounterRxPauseCtrl: {"link_xon_rx.nic", "link_xoff_rx.nic"},
 	CounterTxPauseCtrl: {"link_xon_tx.nic", "link_xoff_tx.nic"},
-}
-
-var iceQueuePattern = regexp.MustCompile(`^(rx|tx)_queue_(\d+)_(packets|bytes)$`)
-
-func processICEBasicStats(rawStats map[string]uint64, stats *BasicStats) {
-	for basicMetric, sourceMetrics := range ICEMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
 
-		switch basicMetric {
-		case CounterRxPackets:
-			stats.RxPackets = total
-		case CounterRxBytes:
-			stats.RxBytes = total
-		case CounterRxDrops:
-			stats.RxDrops = total
-		case CounterTxPackets:
-			stats.TxPackets = total
-		case CounterTxBytes:
-			stats.TxBytes = total
-		case CounterTxDrops:
-			stats.TxDrops = total
-		}
-	}
+	CounterRxUnicast:   {"rx_unicast.nic"},
+	CounterRxMulticast: {"rx_multicast.nic"},
+	CounterRxBroadcast: {"rx_broadcast.nic"},
+	CounterTxUnicast:   {"tx_unicast.nic"},
+	CounterTxMulticast: {"tx_multicast.nic"},
+	CounterTxBroadcast: {"tx_broadcast.nic"},
 }
 
-func processICEPhyStats(rawStats map[string]uint64, phyStats *PhyStats) {
-	for phyMetric, sourceMetrics := range ICEPhyMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
-
-		switch phyMetric {
-		case CounterRxPackets:
-			phyStats.RxPackets = total
-		case CounterRxBytes:
-			phyStats.RxBytes = total
-		case CounterTxPackets:
-			phyStats.TxPackets = total
-		case CounterTxBytes:
-			phyStats.TxBytes = total
-		case CounterRxDiscards:
-			phyStats.RxDiscarded = total
-		case CounterTxDiscards:
-			phyStats.TxDiscarded = total
-		case CounterRxPauseCtrl:
-			phyStats.RxPauseCtrl = total
-		case CounterTxPauseCtrl:
-			phyStats.TxPauseCtrl = total
-		}
-	}
+// ICEQueueMetricMapping defines which queue counters contribute to each queue metric.
+var ICEQueueMetricMapping = map[string][]string{
+	CounterRxPackets: {"rx_packets"},
+	CounterRxBytes:   {"rx_bytes"},
+	CounterTxPackets: {"tx_packets"},
+	CounterTxBytes:   {"tx_bytes"},
 }
 
-func processICEQueueStats(rawStats map[string]uint64) []QueueStats {
-	queueMap := make(map[int]*QueueStats)
-
-	for name, value := range rawStats {
-		matches := iceQueuePattern.FindStringSubmatch(name)
-		if matches == nil {
-			continue
-		}
+const iceQueuePattern = `^(?P<direction>rx|tx)_queue_(?P<queue>\d+)_(?P<counter>packets|bytes)$`
 
-		qIndex, err := strconv.Atoi(matches[2])
-		if err != nil {
-			continue
-		}
-
-		qStats, exists := queueMap[qIndex]
-		if !exists {
-			qStats = &QueueStats{QueueIndex: qIndex}
-			queueMap[qIndex] = qStats
-		}
-
-		switch {
-		case matches[1] == "rx" && matches[3] == "packets":
-			qStats.RxPackets = value
-		case matches[1] == "rx" && matches[3] == "bytes":
-			qStats.RxBytes = value
-		case matches[1] == "tx" && matches[3] == "packets":
-			qStats.TxPackets = value
-		case matches[1] == "tx" && matches[3] == "bytes":
-			qStats.TxBytes = value
-		}
-	}
-
-	result := make([]QueueStats, 0, len(queueMap))
-	for _, stats := range queueMap {
-		result = append(result, *stats)
-	}
-
-	return result
+// ICEPriorityMetricMapping defines which per-priority counters contribute to each PFC metric.
+var ICEPriorityMetricMapping = map[string][]string{
+	CounterPauseFrames: {"rx_xon", "rx_xoff", "tx_xon", "tx_xoff"},
 }
 
-func processICEStats(rawStats map[string]uint64) ProcessedStats {
-	result := ProcessedStats{
-		DriverSpecific: make(map[string]uint64),
-	}
-
-	processICEBasicStats(rawStats, &result.Basic)
-
-	result.Physical = &PhyStats{}
-	processICEPhyStats(rawStats, result.Physical)
-
-	result.PerQueue = processICEQueueStats(rawStats)
+const icePriorityPattern = `^(?P<direction>rx|tx)_priority_(?P<priority>\d)_(?P<counter>xon|xoff)\.nic$`
+
+// ICEErrorMetricMapping defines which source metrics contribute to each error category.
+var ICEErrorMetricMapping = map[string][]string{
+	"rx_" + ErrorCRC:              {"rx_crc_errors.nic"},
+	"rx_" + ErrorLength:           {"rx_length_errors.nic"},
+	"rx_" + ErrorOversize:         {"rx_oversize.nic"},
+	"rx_" + ErrorUndersize:        {"rx_undersize.nic"},
+	"rx_" + ErrorFragments:        {"rx_fragments.nic"},
+	"rx_" + ErrorJabber:           {"rx_jabber.nic"},
+	"rx_" + ErrorBufferExhaustion: {"rx_alloc_fail", "rx_pg_alloc_fail"},
+	"tx_" + ErrorLinkDown:         {"tx_dropped_link_down.nic"},
+}
 
-	for name, value := range rawStats {
-		result.DriverSpecific["raw_"+name] = value
-	}
+// ICERxSizeMetricMapping defines which source metrics contribute to each received packet size bucket.
+var ICERxSizeMetricMapping = map[string][]string{
+	"64":   {"rx_size_64.nic"},
+	"127":  {"rx_size_127.nic"},
+	"255":  {"rx_size_255.nic"},
+	"511":  {"rx_size_511.nic"},
+	"1023": {"rx_size_1023.nic"},
+	"1522": {"rx_size_1522.nic"},
+	"+Inf": {"rx_size_big.nic"},
+}
 
-	return result
+// ICEFECMetricMapping defines which source metrics contribute to each FEC metric.
+var ICEFECMetricMapping = map[string][]string{
+	FECCorrectedBlocks:     {"rx_corrected_fec"},
+	FECUncorrectableBlocks: {"rx_uncorrectable_fec"},
 }
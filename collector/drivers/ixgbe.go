@@ -1,9 +1,20 @@
 package drivers
 
-import (
-	"regexp"
-	"strconv"
-)
+func init() {
+	Register(MustNewMappingDriver(DriverIXGBE, "ixgbe", &Mapping{
+		Basic:  IXGBEMetricMapping,
+		Phy:    IXGBEPhyMetricMapping,
+		Errors: IXGBEErrorMetricMapping,
+		Queue: &QueueMapping{
+			Pattern:  ixgbeQueuePattern,
+			Counters: IXGBEQueueMetricMapping,
+		},
+		PFC: &PriorityMapping{
+			Pattern:  ixgbePriorityPattern,
+			Counters: IXGBEPriorityMetricMapping,
+		},
+	}))
+}
 
 // IXGBEMetricMapping defines which source metrics contribute to each basic metric.
 var IXGBEMetricMapping = map[string][]string{
@@ -20,6 +31,11 @@ var IXGBEMetricMapping = map[string][]string{
 	CounterTxPackets: {"tx_packets"},
 	CounterTxBytes:   {"tx_bytes"},
 	CounterTxDrops:   {"tx_dropped"},
+
+	// ixgbe only counts received multicast and broadcast packets; there is
+	// no unicast or transmit packet type split.
+	CounterRxMulticast: {"multicast"},
+	CounterRxBroadcast: {"broadcast"},
 }
 
 // IXGBEPhyMetricMapping defines which source metrics contribute to each physical metric.
@@ -34,109 +50,30 @@ var IXGBEPhyMetricMapping = map[string][]string{
 	CounterTxPauseCtrl: {"tx_flow_control_xon", "tx_flow_control_xoff"},
 }
 
-var ixgbeQueuePattern = regexp.MustCompile(`^(rx|tx)_queue_(\d+)_(packets|bytes)$`)
-
-func processIXGBEBasicStats(rawStats map[string]uint64, stats *BasicStats) {
-	for basicMetric, sourceMetrics := range IXGBEMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
-
-		switch basicMetric {
-		case CounterRxPackets:
-			stats.RxPackets = total
-		case CounterRxBytes:
-			stats.RxBytes = total
-		case CounterRxDrops:
-			stats.RxDrops = total
-		case CounterTxPackets:
-			stats.TxPackets = total
-		case CounterTxBytes:
-			stats.TxBytes = total
-		case CounterTxDrops:
-			stats.TxDrops = total
-		}
-	}
+// IXGBEQueueMetricMapping defines which queue counters contribute to each queue metric.
+var IXGBEQueueMetricMapping = map[string][]string{
+	CounterRxPackets: {"rx_packets"},
+	CounterRxBytes:   {"rx_bytes"},
+	CounterTxPackets: {"tx_packets"},
+	CounterTxBytes:   {"tx_bytes"},
 }
 
-func processIXGBEPhyStats(rawStats map[string]uint64, phyStats *PhyStats) {
-	for phyMetric, sourceMetrics := range IXGBEPhyMetricMapping {
-		total := sumMetrics(rawStats, sourceMetrics)
+const ixgbeQueuePattern = `^(?P<direction>rx|tx)_queue_(?P<queue>\d+)_(?P<counter>packets|bytes)$`
 
-		switch phyMetric {
-		case CounterRxPackets:
-			phyStats.RxPackets = total
-		case CounterRxBytes:
-			phyStats.RxBytes = total
-		case CounterTxPackets:
-			phyStats.TxPackets = total
-		case CounterTxBytes:
-			phyStats.TxBytes = total
-		case CounterRxDiscards:
-			phyStats.RxDiscarded = total
-		case CounterTxDiscards:
-			phyStats.TxDiscarded = total
-		case CounterRxPauseCtrl:
-			phyStats.RxPauseCtrl = total
-		case CounterTxPauseCtrl:
-			phyStats.TxPauseCtrl = total
-		}
-	}
+// IXGBEPriorityMetricMapping defines which per-priority counters contribute to each PFC metric.
+var IXGBEPriorityMetricMapping = map[string][]string{
+	CounterPauseFrames: {"rx_pxon", "rx_pxoff", "tx_pxon", "tx_pxoff"},
 }
 
-func processIXGBEQueueStats(rawStats map[string]uint64) []QueueStats {
-	queueMap := make(map[int]*QueueStats)
-
-	for name, value := range rawStats {
-		matches := ixgbeQueuePattern.FindStringSubmatch(name)
-		if matches == nil {
-			continue
-		}
-
-		qIndex, err := strconv.Atoi(matches[2])
-		if err != nil {
-			continue
-		}
-
-		qStats, exists := queueMap[qIndex]
-		if !exists {
-			qStats = &QueueStats{QueueIndex: qIndex}
-			queueMap[qIndex] = qStats
-		}
-
-		switch {
-		case matches[1] == "rx" && matches[3] == "packets":
-			qStats.RxPackets = value
-		case matches[1] == "rx" && matches[3] == "bytes":
-			qStats.RxBytes = value
-		case matches[1] == "tx" && matches[3] == "packets":
-			qStats.TxPackets = value
-		case matches[1] == "tx" && matches[3] == "bytes":
-			qStats.TxBytes = value
-		}
-	}
-
-	result := make([]QueueStats, 0, len(queueMap))
-	for _, stats := range queueMap {
-		result = append(result, *stats)
-	}
-
-	return result
-}
-
-func processIXGBEStats(rawStats map[string]uint64) ProcessedStats {
-	result := ProcessedStats{
-		DriverSpecific: make(map[string]uint64),
-	}
-
-	processIXGBEBasicStats(rawStats, &result.Basic)
-
-	result.Physical = &PhyStats{}
-	processIXGBEPhyStats(rawStats, result.Physical)
-
-	result.PerQueue = processIXGBEQueueStats(rawStats)
-
-	for name, value := range rawStats {
-		result.DriverSpecific["raw_"+name] = value
-	}
-
-	return result
+const ixgbePriorityPattern = `^(?P<direction>rx|tx)_pb_(?P<priority>\d)_(?P<counter>pxon|pxoff)$`
+
+// IXGBEErrorMetricMapping defines which source metrics contribute to each error category.
+var IXGBEErrorMetricMapping = map[string][]string{
+	"rx_" + ErrorCRC:              {"rx_crc_errors"},
+	"rx_" + ErrorLength:           {"rx_length_errors"},
+	"rx_" + ErrorAlignment:        {"rx_frame_errors"},
+	"rx_" + ErrorOversize:         {"rx_long_length_errors"},
+	"rx_" + ErrorUndersize:        {"rx_short_length_errors"},
+	"rx_" + ErrorBufferExhaustion: {"rx_no_buffer_count", "alloc_rx_page_failed", "alloc_rx_buff_failed"},
+	"rx_" + ErrorDMA:              {"rx_no_dma_resources"},
 }
@@ -0,0 +1,905 @@
+package drivers
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Named capture groups a queue pattern must define.
+const (
+	queueGroupDirection = "direction"
+	queueGroupQueue     = "queue"
+	queueGroupCounter   = "counter"
+
+	priorityGroupPriority = "priority"
+
+	laneGroupLane = "lane"
+)
+
+// queueCounters are the valid keys of QueueMapping.Counters.
+var queueCounters = map[string]struct{}{
+	CounterRxPackets: {},
+	CounterRxBytes:   {},
+	CounterTxPackets: {},
+	CounterTxBytes:   {},
+	CounterRxDrops:   {},
+	CounterTxDrops:   {},
+}
+
+// packetTypeCounters are the unicast, multicast and broadcast metrics that
+// Mapping.Basic and Mapping.Phy accept.
+var packetTypeCounters = []string{
+	CounterRxUnicast,
+	CounterRxMulticast,
+	CounterRxBroadcast,
+	CounterTxUnicast,
+	CounterTxMulticast,
+	CounterTxBroadcast,
+}
+
+// basicCounters are the valid keys of Mapping.Basic.
+var basicCounters = withCounters(queueCounters, packetTypeCounters...)
+
+// phyCounters are the valid keys of Mapping.Phy.
+var phyCounters = withCounters(map[string]struct{}{
+	CounterRxPackets:   {},
+	CounterRxBytes:     {},
+	CounterTxPackets:   {},
+	CounterTxBytes:     {},
+	CounterRxDiscards:  {},
+	CounterTxDiscards:  {},
+	CounterRxPauseCtrl: {},
+	CounterTxPauseCtrl: {},
+}, packetTypeCounters...)
+
+// withCounters returns a copy of counters with extra keys added.
+func withCounters(counters map[string]struct{}, extra ...string) map[string]struct{} {
+	result := make(map[string]struct{}, len(counters)+len(extra))
+	for key := range counters {
+		result[key] = struct{}{}
+	}
+	for _, key := range extra {
+		result[key] = struct{}{}
+	}
+	return result
+}
+
+// hasAnyCounter reports whether a mapping defines any of the given metrics.
+func hasAnyCounter(mapping map[string][]string, counters []string) bool {
+	for _, counter := range counters {
+		if _, ok := mapping[counter]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// priorityCounters are the valid keys of PriorityMapping.Counters.
+var priorityCounters = map[string]struct{}{
+	CounterPauseFrames:      {},
+	CounterPauseDuration:    {},
+	CounterPauseTransitions: {},
+}
+
+// fecCounters are the valid keys of FECMapping.Counters and
+// FECMapping.LaneCounters.
+var fecCounters = map[string]struct{}{
+	FECCorrectedBlocks:     {},
+	FECUncorrectableBlocks: {},
+	FECCorrectedBits:       {},
+	FECReceivedBits:        {},
+}
+
+// errorCounters are the valid keys of Mapping.Errors: "<direction>_<reason>"
+// for every normalized error reason.
+var errorCounters = func() map[string]struct{} {
+	counters := make(map[string]struct{}, 2*len(ErrorReasons))
+	for _, reason := range ErrorReasons {
+		counters["rx_"+reason] = struct{}{}
+		counters["tx_"+reason] = struct{}{}
+	}
+	return counters
+}()
+
+// Mapping declares which raw ethtool counters contribute to each standard
+// statistic of a driver. The values of every counter listed for a statistic
+// are summed, so e.g. the rx_drops sources of a driver name every counter
+// that represents a dropped received packet.
+type Mapping struct {
+	// Basic maps a basic metric (rx_packets, rx_drops, ...) to its sources.
+	Basic map[string][]string `yaml:"basic,omitempty"`
+	// Phy maps a physical layer metric to its sources. A nil Phy means the
+	// driver has no physical layer statistics.
+	Phy map[string][]string `yaml:"phy,omitempty"`
+	// Errors maps a "<direction>_<reason>" error category (rx_crc,
+	// tx_link_down, ...) to its sources. A nil Errors means the driver has no
+	// error statistics.
+	Errors map[string][]string `yaml:"errors,omitempty"`
+	// RxSizes maps the upper bound in bytes of a received packet size bucket
+	// ("64", "127", ..., "+Inf") to its sources. A nil RxSizes means the
+	// driver has no packet size statistics.
+	RxSizes map[string][]string `yaml:"rx_sizes,omitempty"`
+	// Queue describes per-queue counters. A nil Queue means the driver has
+	// no per-queue statistics.
+	Queue *QueueMapping `yaml:"queue,omitempty"`
+	// PFC describes per-priority flow control counters. A nil PFC means the
+	// driver has no per-priority statistics.
+	PFC *PriorityMapping `yaml:"pfc,omitempty"`
+	// FEC describes forward error correction counters. A nil FEC means the
+	// driver has no FEC statistics.
+	FEC *FECMapping `yaml:"fec,omitempty"`
+
+	// mapped holds the names listed as sources of Basic, Phy, Errors,
+	// RxSizes and FEC counters.
+	mapped map[string]struct{}
+}
+
+// QueueMapping declares how per-queue counters are recognised.
+type QueueMapping struct {
+	// Pattern matches per-queue counter names and must define the named
+	// capture groups "direction", "queue" and "counter", for example
+	// `^(?P<direction>rx|tx)_queue_(?P<queue>\d+)_(?P<counter>packets|bytes)$`.
+	Pattern string `yaml:"pattern"`
+	// Counters maps a queue metric (rx_packets, rx_drops, ...) to the
+	// "<direction>_<counter>" names that contribute to it, e.g.
+	// rx_drops: [rx_wqe_err, rx_buff_alloc_err].
+	Counters map[string][]string `yaml:"counters"`
+
+	re      *regexp.Regexp
+	sources map[string][]string
+}
+
+// PriorityMapping declares how per-priority flow control (PFC) counters are
+// recognised.
+type PriorityMapping struct {
+	// Pattern matches per-priority counter names and must define the named
+	// capture groups "direction", "priority" and "counter", for example
+	// `^(?P<direction>rx|tx)_prio(?P<priority>\d)_(?P<counter>pause.*)$`.
+	Pattern string `yaml:"pattern"`
+	// Counters maps a priority metric (pause_frames, pause_duration,
+	// pause_transitions) to the "<direction>_<counter>" names that
+	// contribute to it, e.g. pause_frames: [rx_xon, rx_xoff, tx_xon, tx_xoff].
+	// Pause durations are in microseconds.
+	Counters map[string][]string `yaml:"counters"`
+
+	re      *regexp.Regexp
+	sources map[string][]string
+}
+
+// FECMapping declares the forward error correction counters of a driver.
+// Only the FEC metrics with at least one source present in the statistics
+// are reported, since NICs only expose them for link modes that use FEC.
+type FECMapping struct {
+	// Counters maps a FEC metric (corrected_blocks, uncorrectable_blocks,
+	// corrected_bits, received_bits) to its sources.
+	Counters map[string][]string `yaml:"counters,omitempty"`
+	// LanePattern matches per-lane counter names and must define the named
+	// capture groups "lane" and "counter", for example
+	// `^rx_err_lane_(?P<lane>\d+)_(?P<counter>phy)$`.
+	LanePattern string `yaml:"lane_pattern,omitempty"`
+	// LaneCounters maps a FEC metric to the "counter" names captured by
+	// LanePattern that contribute to it.
+	LaneCounters map[string][]string `yaml:"lane_counters,omitempty"`
+
+	re      *regexp.Regexp
+	sources map[string][]string
+}
+
+// compile validates the FEC mapping and prepares it for processing.
+func (f *FECMapping) compile() error {
+	if err := validateKeys("fec counter", f.Counters, fecCounters); err != nil {
+		return err
+	}
+	if err := validateKeys("fec lane counter", f.LaneCounters, fecCounters); err != nil {
+		return err
+	}
+	if f.LanePattern == "" {
+		if len(f.LaneCounters) > 0 {
+			return fmt.Errorf("fec lane counters require a lane pattern")
+		}
+		return nil
+	}
+
+	re, err := regexp.Compile(f.LanePattern)
+	if err != nil {
+		return fmt.Errorf("invalid fec lane pattern: %v", err)
+	}
+	for _, group := range []string{laneGroupLane, queueGroupCounter} {
+		if re.SubexpIndex(group) < 0 {
+			return fmt.Errorf("fec lane pattern %q lacks named group %q", f.LanePattern, group)
+		}
+	}
+
+	sources := make(map[string][]string)
+	for metric, names := range f.LaneCounters {
+		for _, name := range names {
+			sources[name] = append(sources[name], metric)
+		}
+	}
+	f.re = re
+	f.sources = sources
+	return nil
+}
+
+// compile validates the queue mapping and prepares it for processing.
+func (q *QueueMapping) compile() error {
+	re, sources, err := compileIndexedMapping("queue", q.Pattern, queueGroupQueue, q.Counters, queueCounters)
+	if err != nil {
+		return err
+	}
+	q.re = re
+	q.sources = sources
+	return nil
+}
+
+// compile validates the priority mapping and prepares it for processing.
+func (p *PriorityMapping) compile() error {
+	re, sources, err := compileIndexedMapping("pfc", p.Pattern, priorityGroupPriority, p.Counters, priorityCounters)
+	if err != nil {
+		return err
+	}
+	p.re = re
+	p.sources = sources
+	return nil
+}
+
+// compileIndexedMapping compiles the pattern of a mapping whose counters are
+// indexed by a queue or priority, and inverts its counters into a lookup from
+// "<direction>_<counter>" to the metrics it contributes to.
+func compileIndexedMapping(kind, pattern, indexGroup string, counters map[string][]string, valid map[string]struct{}) (*regexp.Regexp, map[string][]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid %s pattern: %v", kind, err)
+	}
+	for _, group := range []string{queueGroupDirection, indexGroup, queueGroupCounter} {
+		if re.SubexpIndex(group) < 0 {
+			return nil, nil, fmt.Errorf("%s pattern %q lacks named group %q", kind, pattern, group)
+		}
+	}
+	if err := validateKeys(kind+" counter", counters, valid); err != nil {
+		return nil, nil, err
+	}
+
+	sources := make(map[string][]string)
+	for metric, names := range counters {
+		for _, name := range names {
+			sources[name] = append(sources[name], metric)
+		}
+	}
+	return re, sources, nil
+}
+
+// Validate checks that a mapping only uses known metric names and that its
+// queue pattern is usable.
+func (m *Mapping) Validate() error {
+	if err := validateKeys("basic metric", m.Basic, basicCounters); err != nil {
+		return err
+	}
+	if err := validateKeys("phy metric", m.Phy, phyCounters); err != nil {
+		return err
+	}
+	if err := validateKeys("error category", m.Errors, errorCounters); err != nil {
+		return err
+	}
+	for bound := range m.RxSizes {
+		if _, err := parseSizeBound(bound); err != nil {
+			return err
+		}
+	}
+	if m.Queue != nil {
+		if err := m.Queue.compile(); err != nil {
+			return err
+		}
+	}
+	if m.PFC != nil {
+		if err := m.PFC.compile(); err != nil {
+			return err
+		}
+	}
+	var fecCounters map[string][]string
+	if m.FEC != nil {
+		if err := m.FEC.compile(); err != nil {
+			return err
+		}
+		fecCounters = m.FEC.Counters
+	}
+
+	m.mapped = make(map[string]struct{})
+	for _, section := range []map[string][]string{m.Basic, m.Phy, m.Errors, m.RxSizes, fecCounters} {
+		for _, names := range section {
+			for _, name := range names {
+				m.mapped[name] = struct{}{}
+			}
+		}
+	}
+	return nil
+}
+
+// maps reports whether a raw counter contributes to a standard statistic.
+// The mapping must have been validated.
+func (m *Mapping) maps(counter string) bool {
+	if _, ok := m.mapped[counter]; ok {
+		return true
+	}
+	if m.Queue != nil && indexedSourceMapped(m.Queue.re, m.Queue.sources, counter) {
+		return true
+	}
+	if m.PFC != nil && indexedSourceMapped(m.PFC.re, m.PFC.sources, counter) {
+		return true
+	}
+	if m.FEC != nil && m.FEC.re != nil {
+		if matches := m.FEC.re.FindStringSubmatch(counter); matches != nil {
+			_, ok := m.FEC.sources[matches[m.FEC.re.SubexpIndex(queueGroupCounter)]]
+			return ok
+		}
+	}
+	return false
+}
+
+// indexedSourceMapped reports whether a counter matches the pattern of a
+// queue or priority mapping and its "<direction>_<counter>" name is a source.
+func indexedSourceMapped(re *regexp.Regexp, sources map[string][]string, counter string) bool {
+	matches := re.FindStringSubmatch(counter)
+	if matches == nil {
+		return false
+	}
+	source := matches[re.SubexpIndex(queueGroupDirection)] + "_" + matches[re.SubexpIndex(queueGroupCounter)]
+	_, ok := sources[source]
+	return ok
+}
+
+func validateKeys(kind string, mapping map[string][]string, valid map[string]struct{}) error {
+	for key := range mapping {
+		if _, ok := valid[key]; !ok {
+			return fmt.Errorf("unknown %s %q (valid: %s)", kind, key, joinKeys(valid))
+		}
+	}
+	return nil
+}
+
+// parseSizeBound parses the upper bound of a packet size bucket.
+func parseSizeBound(bound string) (float64, error) {
+	value, err := strconv.ParseFloat(bound, 64)
+	if err != nil || value <= 0 || math.IsNaN(value) {
+		return 0, fmt.Errorf("invalid rx size bucket bound %q (want a positive number of bytes or +Inf)", bound)
+	}
+	return value, nil
+}
+
+func joinKeys(keys map[string]struct{}) string {
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// clone returns a deep copy of the mapping that can be modified without
+// affecting the original.
+func (m *Mapping) clone() *Mapping {
+	c := &Mapping{
+		Basic:   cloneSources(m.Basic),
+		Phy:     cloneSources(m.Phy),
+		Errors:  cloneSources(m.Errors),
+		RxSizes: cloneSources(m.RxSizes),
+	}
+	if m.Queue != nil {
+		c.Queue = &QueueMapping{
+			Pattern:  m.Queue.Pattern,
+			Counters: cloneSources(m.Queue.Counters),
+		}
+	}
+	if m.PFC != nil {
+		c.PFC = &PriorityMapping{
+			Pattern:  m.PFC.Pattern,
+			Counters: cloneSources(m.PFC.Counters),
+		}
+	}
+	if m.FEC != nil {
+		c.FEC = &FECMapping{
+			Counters:     cloneSources(m.FEC.Counters),
+			LanePattern:  m.FEC.LanePattern,
+			LaneCounters: cloneSources(m.FEC.LaneCounters),
+		}
+	}
+	return c
+}
+
+// merge returns a copy of m with the entries of override applied on top.
+// Metrics listed in override replace the sources of the same metric in m.
+func (m *Mapping) merge(override *Mapping) *Mapping {
+	c := m.clone()
+	for metric, sources := range override.Basic {
+		if c.Basic == nil {
+			c.Basic = make(map[string][]string)
+		}
+		c.Basic[metric] = sources
+	}
+	for metric, sources := range override.Phy {
+		if c.Phy == nil {
+			c.Phy = make(map[string][]string)
+		}
+		c.Phy[metric] = sources
+	}
+	for category, sources := range override.Errors {
+		if c.Errors == nil {
+			c.Errors = make(map[string][]string)
+		}
+		c.Errors[category] = sources
+	}
+	for bound, sources := range override.RxSizes {
+		if c.RxSizes == nil {
+			c.RxSizes = make(map[string][]string)
+		}
+		c.RxSizes[bound] = sources
+	}
+	if override.Queue != nil {
+		if c.Queue == nil {
+			c.Queue = &QueueMapping{}
+		}
+		if override.Queue.Pattern != "" {
+			c.Queue.Pattern = override.Queue.Pattern
+		}
+		for metric, sources := range override.Queue.Counters {
+			if c.Queue.Counters == nil {
+				c.Queue.Counters = make(map[string][]string)
+			}
+			c.Queue.Counters[metric] = sources
+		}
+	}
+	if override.PFC != nil {
+		if c.PFC == nil {
+			c.PFC = &PriorityMapping{}
+		}
+		if override.PFC.Pattern != "" {
+			c.PFC.Pattern = override.PFC.Pattern
+		}
+		for metric, sources := range override.PFC.Counters {
+			if c.PFC.Counters == nil {
+				c.PFC.Counters = make(map[string][]string)
+			}
+			c.PFC.Counters[metric] = sources
+		}
+	}
+	if override.FEC != nil {
+		if c.FEC == nil {
+			c.FEC = &FECMapping{}
+		}
+		for metric, sources := range override.FEC.Counters {
+			if c.FEC.Counters == nil {
+				c.FEC.Counters = make(map[string][]string)
+			}
+			c.FEC.Counters[metric] = sources
+		}
+		if override.FEC.LanePattern != "" {
+			c.FEC.LanePattern = override.FEC.LanePattern
+		}
+		for metric, sources := range override.FEC.LaneCounters {
+			if c.FEC.LaneCounters == nil {
+				c.FEC.LaneCounters = make(map[string][]string)
+			}
+			c.FEC.LaneCounters[metric] = sources
+		}
+	}
+	return c
+}
+
+func cloneSources(sources map[string][]string) map[string][]string {
+	if sources == nil {
+		return nil
+	}
+	c := make(map[string][]string, len(sources))
+	for metric, names := range sources {
+		c[metric] = append([]string(nil), names...)
+	}
+	return c
+}
+
+func (m *Mapping) processBasicStats(rawStats map[string]uint64, stats *BasicStats) {
+	for basicMetric, sourceMetrics := range m.Basic {
+		total := sumMetrics(rawStats, sourceMetrics)
+
+		switch basicMetric {
+		case CounterRxPackets:
+			stats.RxPackets = total
+		case CounterRxBytes:
+			stats.RxBytes = total
+		case CounterTxPackets:
+			stats.TxPackets = total
+		case CounterTxBytes:
+			stats.TxBytes = total
+		case CounterRxDrops:
+			stats.RxDrops = total
+		case CounterTxDrops:
+			stats.TxDrops = total
+		case CounterRxUnicast:
+			stats.RxUnicast = total
+		case CounterRxMulticast:
+			stats.RxMulticast = total
+		case CounterRxBroadcast:
+			stats.RxBroadcast = total
+		case CounterTxUnicast:
+			stats.TxUnicast = total
+		case CounterTxMulticast:
+			stats.TxMulticast = total
+		case CounterTxBroadcast:
+			stats.TxBroadcast = total
+		}
+	}
+}
+
+func (m *Mapping) processPhyStats(rawStats map[string]uint64, phyStats *PhyStats) {
+	for phyMetric, sourceMetrics := range m.Phy {
+		total := sumMetrics(rawStats, sourceMetrics)
+
+		switch phyMetric {
+		case CounterRxPackets:
+			phyStats.RxPackets = total
+		case CounterRxBytes:
+			phyStats.RxBytes = total
+		case CounterTxPackets:
+			phyStats.TxPackets = total
+		case CounterTxBytes:
+			phyStats.TxBytes = total
+		case CounterRxDiscards:
+			phyStats.RxDiscarded = total
+		case CounterTxDiscards:
+			phyStats.TxDiscarded = total
+		case CounterRxPauseCtrl:
+			phyStats.RxPauseCtrl = total
+		case CounterTxPauseCtrl:
+			phyStats.TxPauseCtrl = total
+		case CounterRxUnicast:
+			phyStats.RxUnicast = total
+		case CounterRxMulticast:
+			phyStats.RxMulticast = total
+		case CounterRxBroadcast:
+			phyStats.RxBroadcast = total
+		case CounterTxUnicast:
+			phyStats.TxUnicast = total
+		case CounterTxMulticast:
+			phyStats.TxMulticast = total
+		case CounterTxBroadcast:
+			phyStats.TxBroadcast = total
+		}
+	}
+}
+
+func (m *Mapping) processErrorStats(rawStats map[string]uint64, errorStats *ErrorStats) {
+	errorStats.Rx = make(map[string]uint64)
+	errorStats.Tx = make(map[string]uint64)
+
+	for category, sourceMetrics := range m.Errors {
+		total := sumMetrics(rawStats, sourceMetrics)
+
+		// Keys were validated as "rx_<reason>" or "tx_<reason>".
+		direction, reason, _ := strings.Cut(category, "_")
+		switch direction {
+		case "rx":
+			errorStats.Rx[reason] = total
+		case "tx":
+			errorStats.Tx[reason] = total
+		}
+	}
+}
+
+func (m *Mapping) processRxSizes(rawStats map[string]uint64) []SizeBucket {
+	buckets := make([]SizeBucket, 0, len(m.RxSizes))
+	for bound, sourceMetrics := range m.RxSizes {
+		// Bounds were validated by Validate.
+		upperBound, _ := parseSizeBound(bound)
+		buckets = append(buckets, SizeBucket{
+			UpperBound: upperBound,
+			Count:      sumMetrics(rawStats, sourceMetrics),
+		})
+	}
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].UpperBound < buckets[j].UpperBound
+	})
+	return buckets
+}
+
+func (q *QueueMapping) processQueueStats(rawStats map[string]uint64) []QueueStats {
+	queueMap := make(map[int]*QueueStats)
+
+	directionIdx := q.re.SubexpIndex(queueGroupDirection)
+	queueIdx := q.re.SubexpIndex(queueGroupQueue)
+	counterIdx := q.re.SubexpIndex(queueGroupCounter)
+
+	for name, value := range rawStats {
+		matches := q.re.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		qIndex, err := strconv.Atoi(matches[queueIdx])
+		if err != nil {
+			continue
+		}
+
+		qStats, exists := queueMap[qIndex]
+		if !exists {
+			qStats = &QueueStats{QueueIndex: qIndex}
+			queueMap[qIndex] = qStats
+		}
+
+		// e.g. "rx0_wqe_err" is looked up as "rx_wqe_err"
+		source := matches[directionIdx] + "_" + matches[counterIdx]
+		for _, queueMetric := range q.sources[source] {
+			switch queueMetric {
+			case CounterRxPackets:
+				qStats.RxPackets += value
+			case CounterRxBytes:
+				qStats.RxBytes += value
+			case CounterTxPackets:
+				qStats.TxPackets += value
+			case CounterTxBytes:
+				qStats.TxBytes += value
+			case CounterRxDrops:
+				qStats.RxDrops += value
+			case CounterTxDrops:
+				qStats.TxDrops += value
+			}
+		}
+	}
+
+	result := make([]QueueStats, 0, len(queueMap))
+	for _, stats := range queueMap {
+		result = append(result, *stats)
+	}
+
+	return result
+}
+
+func (p *PriorityMapping) processPriorityStats(rawStats map[string]uint64) []PriorityStats {
+	priorityMap := make(map[int]*PriorityStats)
+
+	directionIdx := p.re.SubexpIndex(queueGroupDirection)
+	priorityIdx := p.re.SubexpIndex(priorityGroupPriority)
+	counterIdx := p.re.SubexpIndex(queueGroupCounter)
+
+	for name, value := range rawStats {
+		matches := p.re.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+
+		priority, err := strconv.Atoi(matches[priorityIdx])
+		if err != nil {
+			continue
+		}
+
+		pStats, exists := priorityMap[priority]
+		if !exists {
+			pStats = &PriorityStats{Priority: priority}
+			priorityMap[priority] = pStats
+		}
+
+		// e.g. "rx_priority_3_xoff.nic" is looked up as "rx_xoff"
+		direction := matches[directionIdx]
+		source := direction + "_" + matches[counterIdx]
+		for _, priorityMetric := range p.sources[source] {
+			switch {
+			case priorityMetric == CounterPauseFrames && direction == "rx":
+				pStats.RxPauseFrames += value
+			case priorityMetric == CounterPauseFrames && direction == "tx":
+				pStats.TxPauseFrames += value
+			case priorityMetric == CounterPauseDuration && direction == "rx":
+				pStats.RxPauseDuration += value
+			case priorityMetric == CounterPauseDuration && direction == "tx":
+				pStats.TxPauseDuration += value
+			case priorityMetric == CounterPauseTransitions && direction == "rx":
+				pStats.RxPauseTransitions += value
+			case priorityMetric == CounterPauseTransitions && direction == "tx":
+				pStats.TxPauseTransitions += value
+			}
+		}
+	}
+
+	result := make([]PriorityStats, 0, len(priorityMap))
+	for _, stats := range priorityMap {
+		result = append(result, *stats)
+	}
+
+	return result
+}
+
+func (f *FECMapping) processFECStats(rawStats map[string]uint64) *FECStats {
+	stats := &FECStats{
+		Total: make(map[string]uint64),
+		Lanes: make(map[int]map[string]uint64),
+	}
+
+	for metric, sourceMetrics := range f.Counters {
+		if total, ok := sumPresentMetrics(rawStats, sourceMetrics); ok {
+			stats.Total[metric] = total
+		}
+	}
+
+	if f.re == nil {
+		return stats
+	}
+	laneIdx := f.re.SubexpIndex(laneGroupLane)
+	counterIdx := f.re.SubexpIndex(queueGroupCounter)
+	for name, value := range rawStats {
+		matches := f.re.FindStringSubmatch(name)
+		if matches == nil {
+			continue
+		}
+		lane, err := strconv.Atoi(matches[laneIdx])
+		if err != nil {
+			continue
+		}
+
+		// e.g. "rx_err_lane_2_phy" is looked up as "phy"
+		for _, metric := range f.sources[matches[counterIdx]] {
+			if stats.Lanes[lane] == nil {
+				stats.Lanes[lane] = make(map[string]uint64)
+			}
+			stats.Lanes[lane][metric] += value
+		}
+	}
+	return stats
+}
+
+// sumPresentMetrics sums the values of the named statistics, reporting false
+// if none of them is present.
+func sumPresentMetrics(stats map[string]uint64, names []string) (uint64, bool) {
+	var total uint64
+	present := false
+	for _, name := range names {
+		if value, ok := stats[name]; ok {
+			total += value
+			present = true
+		}
+	}
+	return total, present
+}
+
+func sumMetrics(stats map[string]uint64, names []string) uint64 {
+	var total uint64
+	for _, name := range names {
+		total += stats[name]
+	}
+	return total
+}
+
+// mappingDriver is a Driver whose processing is fully described by a Mapping.
+// Its mapping can be replaced at runtime by a mapping file.
+type mappingDriver struct {
+	name     string
+	prefix   string
+	defaults *Mapping
+	mapping  atomic.Pointer[Mapping]
+	// fromFile marks drivers defined by a mapping file rather than code.
+	fromFile bool
+}
+
+// NewMappingDriver returns a Driver that processes statistics according to
+// the given mapping. The mapping becomes the driver's built-in default that
+// mapping files merge over.
+func NewMappingDriver(name, prefix string, mapping *Mapping) (Driver, error) {
+	m := mapping.clone()
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("driver %s: %v", name, err)
+	}
+
+	d := &mappingDriver{
+		name:     name,
+		prefix:   prefix,
+		defaults: m,
+	}
+	d.mapping.Store(m)
+	return d, nil
+}
+
+// MustNewMappingDriver is like NewMappingDriver but panics on an invalid mapping.
+func MustNewMappingDriver(name, prefix string, mapping *Mapping) Driver {
+	d, err := NewMappingDriver(name, prefix, mapping)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func (d *mappingDriver) Name() string   { return d.name }
+func (d *mappingDriver) Prefix() string { return d.prefix }
+
+func (d *mappingDriver) Capabilities() Capability {
+	m := d.mapping.Load()
+
+	var caps Capability
+	if hasAnyCounter(m.Basic, packetTypeCounters) {
+		caps |= CapPacketTypes
+	}
+	if m.Phy != nil {
+		caps |= CapPhysical
+		if hasAnyCounter(m.Phy, packetTypeCounters) {
+			caps |= CapPhyPacketTypes
+		}
+	}
+	if m.Errors != nil {
+		caps |= CapErrors
+	}
+	if m.RxSizes != nil {
+		caps |= CapRxSizes
+	}
+	if m.Queue != nil {
+		caps |= CapPerQueue
+	}
+	if m.FEC != nil {
+		caps |= CapFEC
+	}
+	if m.PFC != nil {
+		caps |= CapPerPriority
+		if _, ok := m.PFC.Counters[CounterPauseDuration]; ok {
+			caps |= CapPauseDuration
+		}
+		if _, ok := m.PFC.Counters[CounterPauseTransitions]; ok {
+			caps |= CapPauseTransitions
+		}
+	}
+	return caps
+}
+
+func (d *mappingDriver) ReportsPacketType(counter string, physical bool) bool {
+	m := d.mapping.Load()
+	section := m.Basic
+	if physical {
+		section = m.Phy
+	}
+	_, ok := section[counter]
+	return ok
+}
+
+func (d *mappingDriver) Maps(counter string) bool {
+	return d.mapping.Load().maps(counter)
+}
+
+func (d *mappingDriver) Process(rawStats map[string]uint64) ProcessedStats {
+	m := d.mapping.Load()
+
+	result := ProcessedStats{
+		DriverSpecific: make(map[string]uint64),
+	}
+
+	m.processBasicStats(rawStats, &result.Basic)
+
+	if m.Phy != nil {
+		result.Physical = &PhyStats{}
+		m.processPhyStats(rawStats, result.Physical)
+	}
+
+	if m.Errors != nil {
+		result.Errors = &ErrorStats{}
+		m.processErrorStats(rawStats, result.Errors)
+	}
+
+	if m.RxSizes != nil {
+		result.RxSizes = m.processRxSizes(rawStats)
+	}
+
+	if m.Queue != nil {
+		result.PerQueue = m.Queue.processQueueStats(rawStats)
+	}
+
+	if m.PFC != nil {
+		result.PerPriority = m.PFC.processPriorityStats(rawStats)
+	}
+
+	if m.FEC != nil {
+		result.FEC = m.FEC.processFECStats(rawStats)
+	}
+
+	for name, value := range rawStats {
+		result.DriverSpecific["raw_"+name] = value
+	}
+
+	return result
+}
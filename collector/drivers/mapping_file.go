@@ -0,0 +1,146 @@
+package drivers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MappingFile is the on-disk format of a counter mapping file. JSON files are
+// accepted too, since JSON is a subset of YAML.
+//
+//	drivers:
+//	  ice:
+//	    basic:
+//	      rx_drops: [rx_dropped, rx_alloc_fail]
+//	  bnxt_en:
+//	    prefix: bnxt
+//	    replace: true
+//	    basic:
+//	      rx_packets: [rx_ucast_packets, rx_mcast_packets, rx_bcast_packets]
+type MappingFile struct {
+	Drivers map[string]DriverMappingConfig `yaml:"drivers"`
+}
+
+// DriverMappingConfig is the mapping definition of a single driver in a
+// mapping file.
+type DriverMappingConfig struct {
+	// Prefix sets the metric prefix of a driver that has no built-in
+	// processor. It defaults to the driver name.
+	Prefix string `yaml:"prefix,omitempty"`
+	// Replace discards the built-in mapping instead of merging over it.
+	Replace bool `yaml:"replace,omitempty"`
+
+	Mapping `yaml:",inline"`
+}
+
+// LoadMappingFile reads a mapping file and applies it to the driver registry.
+// Definitions are merged over the built-in mappings, or replace them when
+// "replace" is set; drivers without a built-in processor are registered.
+// Built-in drivers absent from the file revert to their defaults, so the
+// file can be loaded again to pick up changes. Nothing is applied if any
+// definition is invalid.
+func LoadMappingFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read mapping file: %v", err)
+	}
+
+	var file MappingFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("failed to parse mapping file %s: %v", path, err)
+	}
+
+	return ApplyMappings(file.Drivers)
+}
+
+// ApplyMappings validates driver mapping definitions and applies them to the
+// driver registry as described for LoadMappingFile.
+func ApplyMappings(configs map[string]DriverMappingConfig) error {
+	names := make([]string, 0, len(configs))
+	for name := range configs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	mappings := make(map[string]*Mapping, len(configs))
+	registered := make(map[string]*mappingDriver)
+	var errs []error
+	for _, name := range names {
+		config := configs[name]
+
+		registryMu.RLock()
+		existing, exists := registry[name]
+		registryMu.RUnlock()
+
+		var mapping *Mapping
+		switch d, ok := existing.(*mappingDriver); {
+		case !exists:
+			mapping = config.Mapping.clone()
+		case !ok:
+			errs = append(errs, fmt.Errorf("driver %s: processor does not support mapping files", name))
+			continue
+		case config.Replace || d.fromFile:
+			mapping = config.Mapping.clone()
+			registered[name] = d
+		default:
+			mapping = d.defaults.merge(&config.Mapping)
+			registered[name] = d
+		}
+
+		if err := mapping.Validate(); err != nil {
+			errs = append(errs, fmt.Errorf("driver %s: %v", name, err))
+			continue
+		}
+		mappings[name] = mapping
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	// Reset built-in mapping drivers that the file no longer mentions and
+	// drop drivers that an earlier file defined.
+	registryMu.Lock()
+	for name, d := range registry {
+		md, ok := d.(*mappingDriver)
+		if !ok {
+			continue
+		}
+		if _, mentioned := configs[name]; mentioned {
+			continue
+		}
+		if md.fromFile {
+			delete(registry, name)
+		} else {
+			md.mapping.Store(md.defaults)
+		}
+	}
+	registryMu.Unlock()
+
+	for _, name := range names {
+		if d, ok := registered[name]; ok {
+			d.mapping.Store(mappings[name])
+			continue
+		}
+
+		prefix := configs[name].Prefix
+		if prefix == "" {
+			prefix = genericMetricPrefix(name)
+		}
+		d, err := NewMappingDriver(name, prefix, mappings[name])
+		if err != nil {
+			return err
+		}
+		d.(*mappingDriver).fromFile = true
+		Register(d)
+	}
+
+	return nil
+}
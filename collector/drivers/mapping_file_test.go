@@ -0,0 +1,321 @@
+package drivers
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMappingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping Mapping
+		wantErr string
+	}{
+		{
+			name: "valid",
+			mapping: Mapping{
+				Basic:   map[string][]string{CounterRxPackets: {"rx_packets"}},
+				RxSizes: map[string][]string{"64": {"rx_64"}, "+Inf": {"rx_big"}},
+				Queue: &QueueMapping{
+					Pattern:  `^(?P<direction>rx|tx)_q(?P<queue>\d+)_(?P<counter>packets)$`,
+					Counters: map[string][]string{CounterRxPackets: {"rx_packets"}},
+				},
+			},
+		},
+		{
+			name:    "unknown basic metric",
+			mapping: Mapping{Basic: map[string][]string{"rx_frobs": {"x"}}},
+			wantErr: `unknown basic metric "rx_frobs"`,
+		},
+		{
+			name:    "unknown phy metric",
+			mapping: Mapping{Phy: map[string][]string{"tx_frobs": {"x"}}},
+			wantErr: `unknown phy metric "tx_frobs"`,
+		},
+		{
+			name:    "invalid size bound",
+			mapping: Mapping{RxSizes: map[string][]string{"-1": {"x"}}},
+			wantErr: `invalid rx size bucket bound "-1"`,
+		},
+		{
+			name:    "invalid queue pattern",
+			mapping: Mapping{Queue: &QueueMapping{Pattern: `(`}},
+			wantErr: "invalid queue pattern",
+		},
+		{
+			name:    "queue pattern without queue group",
+			mapping: Mapping{Queue: &QueueMapping{Pattern: `^(?P<direction>rx|tx)_(?P<counter>packets)$`}},
+			wantErr: `lacks named group "queue"`,
+		},
+		{
+			name:    "pfc pattern without priority group",
+			mapping: Mapping{PFC: &PriorityMapping{Pattern: `^(?P<direction>rx|tx)_(?P<counter>pause)$`}},
+			wantErr: `lacks named group "priority"`,
+		},
+		{
+			name:    "fec lane counters without pattern",
+			mapping: Mapping{FEC: &FECMapping{LaneCounters: map[string][]string{FECCorrectedBlocks: {"corr"}}}},
+			wantErr: "fec lane counters require a lane pattern",
+		},
+		{
+			name:    "fec lane pattern without lane group",
+			mapping: Mapping{FEC: &FECMapping{LanePattern: `^fec_(?P<counter>corr)$`}},
+			wantErr: `lacks named group "lane"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.mapping.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMappingMerge(t *testing.T) {
+	base := &Mapping{
+		Basic: map[string][]string{
+			CounterRxPackets: {"rx_unicast"},
+			CounterRxDrops:   {"rx_dropped"},
+		},
+		Queue: &QueueMapping{
+			Pattern:  `^(?P<direction>rx|tx)_q(?P<queue>\d+)_(?P<counter>packets)$`,
+			Counters: map[string][]string{CounterRxPackets: {"rx_packets"}},
+		},
+	}
+	merged := base.merge(&Mapping{
+		Basic: map[string][]string{CounterRxDrops: {"rx_dropped", "rx_alloc_fail"}},
+	})
+
+	if got := merged.Basic[CounterRxDrops]; strings.Join(got, ",") != "rx_dropped,rx_alloc_fail" {
+		t.Errorf("merged rx_drops = %v, want override", got)
+	}
+	if got := merged.Basic[CounterRxPackets]; strings.Join(got, ",") != "rx_unicast" {
+		t.Errorf("merged rx_packets = %v, want base sources", got)
+	}
+	if merged.Queue == nil || merged.Queue.Pattern != base.Queue.Pattern {
+		t.Errorf("merged queue = %+v, want base queue mapping", merged.Queue)
+	}
+
+	merged.Basic[CounterRxPackets][0] = "changed"
+	merged.Queue.Counters[CounterRxPackets][0] = "changed"
+	if base.Basic[CounterRxPackets][0] != "rx_unicast" || base.Queue.Counters[CounterRxPackets][0] != "rx_packets" {
+		t.Error("modifying the merged mapping changed the base mapping")
+	}
+}
+
+// writeMappingFile writes a mapping file and restores the built-in mappings
+// when the test ends.
+func writeMappingFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mappings.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := ApplyMappings(nil); err != nil {
+			t.Errorf("failed to restore built-in mappings: %v", err)
+		}
+	})
+	return path
+}
+
+func TestLoadMappingFileMergesOverBuiltin(t *testing.T) {
+	path := writeMappingFile(t, `
+drivers:
+  ice:
+    basic:
+      rx_drops: [rx_dropped]
+`)
+	if err := LoadMappingFile(path); err != nil {
+		t.Fatalf("LoadMappingFile() = %v", err)
+	}
+
+	stats := ProcessDriverStats(DriverICE, map[string]uint64{
+		"rx_unicast":    10,
+		"rx_multicast":  2,
+		"rx_dropped":    3,
+		"rx_alloc_fail": 4,
+	})
+	if stats.Basic.RxDrops != 3 {
+		t.Errorf("RxDrops = %d, want 3 from the overridden sources", stats.Basic.RxDrops)
+	}
+	if stats.Basic.RxPackets != 12 {
+		t.Errorf("RxPackets = %d, want 12 from the built-in sources", stats.Basic.RxPackets)
+	}
+	if !GetCapabilities(DriverICE).Has(CapPerQueue) {
+		t.Error("merged ice mapping lost its queue mapping")
+	}
+}
+
+func TestLoadMappingFileReplace(t *testing.T) {
+	path := writeMappingFile(t, `
+drivers:
+  ice:
+    replace: true
+    basic:
+      rx_packets: [rx_unicast]
+`)
+	if err := LoadMappingFile(path); err != nil {
+		t.Fatalf("LoadMappingFile() = %v", err)
+	}
+
+	stats := ProcessDriverStats(DriverICE, map[string]uint64{"rx_unicast": 10, "rx_multicast": 2})
+	if stats.Basic.RxPackets != 10 {
+		t.Errorf("RxPackets = %d, want 10", stats.Basic.RxPackets)
+	}
+	if GetCapabilities(DriverICE).Has(CapPerQueue) {
+		t.Error("replaced ice mapping kept the built-in queue mapping")
+	}
+}
+
+func TestLoadMappingFileRegistersDriver(t *testing.T) {
+	path := writeMappingFile(t, `
+drivers:
+  bnxt_en:
+    basic:
+      rx_packets: [rx_ucast_packets, rx_mcast_packets]
+  fake_nic:
+    prefix: fake
+    basic:
+      tx_bytes: [tx_octets]
+`)
+	if err := LoadMappingFile(path); err != nil {
+		t.Fatalf("LoadMappingFile() = %v", err)
+	}
+
+	if !IsSupportedDriver("bnxt_en") {
+		t.Fatal("bnxt_en was not registered")
+	}
+	if got := GetMetricPrefix("bnxt_en"); got != genericMetricPrefix("bnxt_en") {
+		t.Errorf("bnxt_en prefix = %q, want the generic prefix %q", got, genericMetricPrefix("bnxt_en"))
+	}
+	if got := GetMetricPrefix("fake_nic"); got != "fake" {
+		t.Errorf("fake_nic prefix = %q, want fake", got)
+	}
+	stats := ProcessDriverStats("bnxt_en", map[string]uint64{"rx_ucast_packets": 5, "rx_mcast_packets": 1})
+	if stats.Basic.RxPackets != 6 {
+		t.Errorf("RxPackets = %d, want 6", stats.Basic.RxPackets)
+	}
+	if unmapped, ok := UnmappedCounters("bnxt_en", map[string]uint64{"rx_ucast_packets": 5, "other": 1}); !ok || unmapped != 1 {
+		t.Errorf("UnmappedCounters() = %d, %v, want 1, true", unmapped, ok)
+	}
+}
+
+func TestLoadMappingFileReload(t *testing.T) {
+	path := writeMappingFile(t, `
+drivers:
+  ice:
+    basic:
+      rx_drops: [rx_dropped]
+  bnxt_en:
+    basic:
+      rx_packets: [rx_ucast_packets]
+`)
+	if err := LoadMappingFile(path); err != nil {
+		t.Fatalf("LoadMappingFile() = %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("drivers: {}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadMappingFile(path); err != nil {
+		t.Fatalf("reloading LoadMappingFile() = %v", err)
+	}
+
+	if IsSupportedDriver("bnxt_en") {
+		t.Error("bnxt_en is still registered after being removed from the file")
+	}
+	stats := ProcessDriverStats(DriverICE, map[string]uint64{"rx_dropped": 3, "rx_alloc_fail": 4})
+	if stats.Basic.RxDrops != 7 {
+		t.Errorf("RxDrops = %d, want 7 from the restored built-in sources", stats.Basic.RxDrops)
+	}
+}
+
+func TestLoadMappingFileInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "unknown field",
+			content: `
+drivers:
+  ice:
+    basics:
+      rx_drops: [rx_dropped]
+`,
+			wantErr: "field basics not found",
+		},
+		{
+			name: "unknown metric",
+			content: `
+drivers:
+  ice:
+    basic:
+      rx_frobs: [rx_dropped]
+`,
+			wantErr: `driver ice: unknown basic metric "rx_frobs"`,
+		},
+		{
+			name: "invalid queue pattern",
+			content: `
+drivers:
+  bnxt_en:
+    queue:
+      pattern: '^rx_q(?P<queue>\d+)$'
+`,
+			wantErr: `driver bnxt_en: queue pattern`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A valid definition next to the invalid one must not be applied.
+			path := writeMappingFile(t, tt.content+`
+  i40e:
+    basic:
+      rx_drops: [rx_dropped]
+`)
+			raw := map[string]uint64{"rx_dropped": 3, "rx_missed_errors": 4}
+			want := ProcessDriverStats(DriverI40E, raw)
+
+			err := LoadMappingFile(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("LoadMappingFile() = %v, want error containing %q", err, tt.wantErr)
+			}
+			if IsSupportedDriver("bnxt_en") {
+				t.Error("bnxt_en was registered from an invalid file")
+			}
+			stats := ProcessDriverStats(DriverI40E, raw)
+			if stats.Basic != want.Basic {
+				t.Errorf("i40e stats = %+v, want built-in %+v", stats.Basic, want.Basic)
+			}
+		})
+	}
+}
+
+func TestApplyMappingsRejectsNonMappingDriver(t *testing.T) {
+	Register(NewDriver("test_func", "test", 0, processGenericStats))
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "test_func")
+		registryMu.Unlock()
+	})
+
+	err := ApplyMappings(map[string]DriverMappingConfig{"test_func": {}})
+	if err == nil || !strings.Contains(err.Error(), "does not support mapping files") {
+		t.Fatalf("ApplyMappings() = %v, want unsupported processor error", err)
+	}
+}
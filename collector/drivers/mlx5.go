@@ -1,10 +1,26 @@
 package drivers
 
-import (
-	"regexp"
-	"strconv"
-	"strings"
-)
+func init() {
+	Register(MustNewMappingDriver(DriverMLX5, "mlx5", &Mapping{
+		Basic:   MLX5MetricMapping,
+		Phy:     MLX5PhyMetricMapping,
+		Errors:  MLX5ErrorMetricMapping,
+		RxSizes: MLX5RxSizeMetricMapping,
+		Queue: &QueueMapping{
+			Pattern:  mlx5QueuePattern,
+			Counters: MLX5QueueMetricMapping,
+		},
+		PFC: &PriorityMapping{
+			Pattern:  mlx5PriorityPattern,
+			Counters: MLX5PriorityMetricMapping,
+		},
+		FEC: &FECMapping{
+			Counters:     MLX5FECMetricMapping,
+			LanePattern:  mlx5FECLanePattern,
+			LaneCounters: MLX5FECLaneMetricMapping,
+		},
+	}))
+}
 
 // Define constants for counter names
 const (
@@ -15,11 +31,24 @@ const (
 	CounterRxDrops   = "rx_drops"
 	CounterTxDrops   = "tx_drops"
 
+	// Packet type metrics
+	CounterRxUnicast   = "rx_unicast"
+	CounterRxMulticast = "rx_multicast"
+	CounterRxBroadcast = "rx_broadcast"
+	CounterTxUnicast   = "tx_unicast"
+	CounterTxMulticast = "tx_multicast"
+	CounterTxBroadcast = "tx_broadcast"
+
 	// Physical metrics
 	CounterRxDiscards  = "rx_discards"
 	CounterTxDiscards  = "tx_discards"
 	CounterRxPauseCtrl = "rx_pause_ctrl"
 	CounterTxPauseCtrl = "tx_pause_ctrl"
+
+	// Per-priority flow control metrics
+	CounterPauseFrames      = "pause_frames"
+	CounterPauseDuration    = "pause_duration"
+	CounterPauseTransitions = "pause_transitions"
 )
 
 // MLX5MetricMapping defines which source metrics contribute to each basic metric
@@ -30,6 +59,13 @@ var MLX5MetricMapping = map[string][]string{
 	CounterTxBytes:   {"tx_bytes"},
 	CounterRxDrops:   {"rx_out_of_buffer", "rx_buff_alloc_err", "rx_wqe_err"},
 	CounterTxDrops:   {"tx_cqe_err"},
+
+	CounterRxUnicast:   {"rx_vport_unicast_packets"},
+	CounterRxMulticast: {"rx_vport_multicast_packets"},
+	CounterRxBroadcast: {"rx_vport_broadcast_packets"},
+	CounterTxUnicast:   {"tx_vport_unicast_packets"},
+	CounterTxMulticast: {"tx_vport_multicast_packets"},
+	CounterTxBroadcast: {"tx_vport_broadcast_packets"},
 }
 
 // MLX5PhyMetricMapping defines which source metrics contribute to each physical metric
@@ -54,164 +90,54 @@ var MLX5QueueMetricMapping = map[string][]string{
 	CounterTxDrops:   {"tx_cqe_err"},
 }
 
-// Queue metric patterns
-var (
-	mlx5QueuePattern = regexp.MustCompile(`^(rx|tx)(\d+)_`)
-)
+const mlx5QueuePattern = `^(?P<direction>rx|tx)(?P<queue>\d+)_(?P<counter>.+)$`
 
-// processMLX5BasicStats processes standard statistics for MLX5 NICs
-func processMLX5BasicStats(rawStats map[string]uint64, stats *BasicStats) {
-	// Process each basic metric using the mapping
-	for basicMetric, sourceMetrics := range MLX5MetricMapping {
-		var total uint64
-		for _, sourceMetric := range sourceMetrics {
-			if value, exists := rawStats[sourceMetric]; exists {
-				total += value
-			}
-		}
-
-		// Assign the total to the appropriate field in BasicStats
-		switch basicMetric {
-		case CounterRxPackets:
-			stats.RxPackets = total
-		case CounterRxBytes:
-			stats.RxBytes = total
-		case CounterTxPackets:
-			stats.TxPackets = total
-		case CounterTxBytes:
-			stats.TxBytes = total
-		case CounterRxDrops:
-			stats.RxDrops = total
-		case CounterTxDrops:
-			stats.TxDrops = total
-		}
-	}
+// MLX5PriorityMetricMapping defines which per-priority counters contribute to each PFC metric
+var MLX5PriorityMetricMapping = map[string][]string{
+	CounterPauseFrames:      {"rx_pause", "tx_pause"},
+	CounterPauseDuration:    {"rx_pause_duration", "tx_pause_duration"},
+	CounterPauseTransitions: {"rx_pause_transition", "tx_pause_transition"},
 }
 
-// processMLX5PhyStats processes physical layer statistics for MLX5 NICs
-func processMLX5PhyStats(metrics map[string]uint64, phyStats *PhyStats) {
-	// Process each physical metric using the mapping
-	for phyMetric, sourceMetrics := range MLX5PhyMetricMapping {
-		var total uint64
-		for _, sourceMetric := range sourceMetrics {
-			if value, exists := metrics[sourceMetric]; exists {
-				total += value
-			}
-		}
-
-		// Assign the total to the appropriate field in PhyStats
-		switch phyMetric {
-		case CounterRxPackets:
-			phyStats.RxPackets = total
-		case CounterRxBytes:
-			phyStats.RxBytes = total
-		case CounterTxPackets:
-			phyStats.TxPackets = total
-		case CounterTxBytes:
-			phyStats.TxBytes = total
-		case CounterRxDiscards:
-			phyStats.RxDiscarded = total
-		case CounterTxDiscards:
-			phyStats.TxDiscarded = total
-		case CounterRxPauseCtrl:
-			phyStats.RxPauseCtrl = total
-		case CounterTxPauseCtrl:
-			phyStats.TxPauseCtrl = total
-		}
-	}
+const mlx5PriorityPattern = `^(?P<direction>rx|tx)_prio(?P<priority>\d)_(?P<counter>pause|pause_duration|pause_transition)$`
+
+// MLX5ErrorMetricMapping defines which source metrics contribute to each error category
+var MLX5ErrorMetricMapping = map[string][]string{
+	"rx_" + ErrorCRC:              {"rx_crc_errors_phy"},
+	"rx_" + ErrorLength:           {"rx_in_range_len_errors_phy", "rx_out_of_range_len_phy"},
+	"rx_" + ErrorOversize:         {"rx_oversize_pkts_phy"},
+	"rx_" + ErrorUndersize:        {"rx_undersize_pkts_phy"},
+	"rx_" + ErrorFragments:        {"rx_fragments_phy"},
+	"rx_" + ErrorJabber:           {"rx_jabbers_phy"},
+	"rx_" + ErrorBufferExhaustion: {"rx_out_of_buffer", "rx_buff_alloc_err"},
+	"rx_" + ErrorDMA:              {"rx_wqe_err"},
+	"tx_" + ErrorDMA:              {"tx_cqe_err"},
 }
 
-// processMLX5QueueStats processes per-queue statistics for MLX5 NICs
-func processMLX5QueueStats(metrics map[string]uint64) []QueueStats {
-	// Map to store queue stats by index
-	queueMap := make(map[int]*QueueStats)
-
-	// Process each metric
-	for name, value := range metrics {
-		// Extract queue index and base metric name
-		if matches := mlx5QueuePattern.FindStringSubmatch(name); matches != nil {
-			qIndex, err := strconv.Atoi(matches[2])
-			if err != nil {
-				continue
-			}
-
-			// Get or create queue stats
-			qStats, exists := queueMap[qIndex]
-			if !exists {
-				queueMap[qIndex] = &QueueStats{
-					QueueIndex: qIndex,
-				}
-				qStats = queueMap[qIndex]
-			}
-
-			// Process each queue metric using the mapping
-			for queueMetric, sourceMetrics := range MLX5QueueMetricMapping {
-				// Check if this metric matches any of the source metrics for this queue metric
-				for _, sourceMetric := range sourceMetrics {
-					// Construct the expected metric name with queue index
-					// e.g., if sourceMetric is "rx_packets", and qIndex is 0,
-					// expectedName would be "rx0_packets"
-					direction := strings.Split(sourceMetric, "_")[0]          // "rx" or "tx"
-					suffix := strings.TrimPrefix(sourceMetric, direction+"_") // "packets", "bytes", etc
-					expectedName := direction + strconv.Itoa(qIndex) + "_" + suffix
-
-					if name == expectedName {
-						switch queueMetric {
-						case CounterRxPackets:
-							qStats.RxPackets = value
-						case CounterRxBytes:
-							qStats.RxBytes = value
-						case CounterTxPackets:
-							qStats.TxPackets = value
-						case CounterTxBytes:
-							qStats.TxBytes = value
-						case CounterRxDrops:
-							qStats.RxDrops += value
-						case CounterTxDrops:
-							qStats.TxDrops += value
-						}
-					}
-				}
-			}
-		}
-	}
-
-	// Convert map to slice
-	var result = make([]QueueStats, 0, len(queueMap))
-	for _, stats := range queueMap {
-		result = append(result, *stats)
-	}
-
-	return result
+// MLX5RxSizeMetricMapping defines which source metrics contribute to each received packet size bucket
+var MLX5RxSizeMetricMapping = map[string][]string{
+	"64":    {"rx_64_bytes_phy"},
+	"127":   {"rx_65_to_127_bytes_phy"},
+	"255":   {"rx_128_to_255_bytes_phy"},
+	"511":   {"rx_256_to_511_bytes_phy"},
+	"1023":  {"rx_512_to_1023_bytes_phy"},
+	"1518":  {"rx_1024_to_1518_bytes_phy"},
+	"2047":  {"rx_1519_to_2047_bytes_phy"},
+	"4095":  {"rx_2048_to_4095_bytes_phy"},
+	"8191":  {"rx_4096_to_8191_bytes_phy"},
+	"10239": {"rx_8192_to_10239_bytes_phy"},
 }
 
-// processMLX5CustomStats processes MLX5-specific statistics
-func processMLX5CustomStats(metrics map[string]uint64, driverSpecific map[string]uint64) {
-
+// MLX5FECMetricMapping defines which source metrics contribute to each FEC
+// metric. mlx5 reports the bit errors corrected by FEC rather than codewords.
+var MLX5FECMetricMapping = map[string][]string{
+	FECCorrectedBits: {"rx_corrected_bits_phy"},
+	FECReceivedBits:  {"rx_bits_phy"},
 }
 
-func processMLX5Stats(rawStats map[string]uint64) ProcessedStats {
-	result := ProcessedStats{
-		DriverSpecific: make(map[string]uint64),
-	}
-
-	// Process basic metrics
-	processMLX5BasicStats(rawStats, &result.Basic)
-
-	// Process physical metrics
-	result.Physical = &PhyStats{}
-	processMLX5PhyStats(rawStats, result.Physical)
-
-	// Process queue metrics
-	result.PerQueue = processMLX5QueueStats(rawStats)
-
-	// Process custom metrics and store raw metrics
-	processMLX5CustomStats(rawStats, result.DriverSpecific)
-
-	// Copy all raw metrics to DriverSpecific
-	for name, value := range rawStats {
-		result.DriverSpecific["raw_"+name] = value
-	}
-
-	return result
+// MLX5FECLaneMetricMapping defines which per-lane counters contribute to each FEC metric
+var MLX5FECLaneMetricMapping = map[string][]string{
+	FECCorrectedBits: {"phy"},
 }
+
+const mlx5FECLanePattern = `^rx_err_lane_(?P<lane>\d+)_(?P<counter>phy)$`
@@ -0,0 +1,155 @@
+package drivers
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// Capability describes the optional statistics a driver processor provides.
+type Capability uint
+
+const (
+	// CapPhysical means the driver fills ProcessedStats.Physical.
+	CapPhysical Capability = 1 << iota
+	// CapPerQueue means the driver fills ProcessedStats.PerQueue.
+	CapPerQueue
+	// CapPerPriority means the driver fills the pause frame counts of
+	// ProcessedStats.PerPriority.
+	CapPerPriority
+	// CapPauseDuration means the driver fills the per-priority pause
+	// durations.
+	CapPauseDuration
+	// CapPauseTransitions means the driver fills the per-priority pause
+	// transition counts.
+	CapPauseTransitions
+	// CapErrors means the driver fills ProcessedStats.Errors.
+	CapErrors
+	// CapPacketTypes means the driver fills the unicast, multicast and
+	// broadcast counters of ProcessedStats.Basic.
+	CapPacketTypes
+	// CapPhyPacketTypes means the driver fills the unicast, multicast and
+	// broadcast counters of ProcessedStats.Physical.
+	CapPhyPacketTypes
+	// CapRxSizes means the driver fills ProcessedStats.RxSizes.
+	CapRxSizes
+	// CapFEC means the driver fills ProcessedStats.FEC.
+	CapFEC
+)
+
+// Has reports whether c includes every capability in other.
+func (c Capability) Has(other Capability) bool {
+	return c&other == other
+}
+
+// Driver processes the raw ethtool statistics of a NIC driver.
+//
+// Drivers in this package register themselves in init(). Binaries importing
+// this package can add their own processors the same way by calling Register.
+type Driver interface {
+	// Name returns the kernel driver name as reported by ethtool -i,
+	// e.g. "mlx5_core".
+	Name() string
+	// Prefix returns the prefix used for driver-specific metric names,
+	// e.g. "mlx5".
+	Prefix() string
+	// Process converts raw ethtool statistics into ProcessedStats.
+	Process(rawStats map[string]uint64) ProcessedStats
+	// Capabilities reports which optional statistics Process fills in.
+	Capabilities() Capability
+}
+
+// CounterMapper is implemented by drivers that can tell which raw counters
+// Process turns into standard statistics. Counters it does not map are only
+// exported as driver-specific metrics.
+type CounterMapper interface {
+	Maps(counter string) bool
+}
+
+// PacketTypeReporter is implemented by drivers that fill only some of the
+// packet type counters. Drivers that do not implement it fill all of them
+// when they report CapPacketTypes or CapPhyPacketTypes.
+type PacketTypeReporter interface {
+	// ReportsPacketType reports whether Process fills a packet type counter
+	// (rx_unicast, tx_broadcast, ...) of ProcessedStats.Basic, or of
+	// ProcessedStats.Physical if physical is set.
+	ReportsPacketType(counter string, physical bool) bool
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Driver)
+)
+
+// Register makes a driver processor available under its Name. It panics if
+// the driver is nil or a driver with the same name is already registered.
+func Register(d Driver) {
+	if d == nil {
+		panic("drivers: Register driver is nil")
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	name := d.Name()
+	if _, exists := registry[name]; exists {
+		panic(fmt.Sprintf("drivers: Register called twice for driver %s", name))
+	}
+	registry[name] = d
+}
+
+// Lookup returns the processor registered for a driver name. If none is
+// registered and the generic fallback is enabled, a generic processor for
+// that driver is returned.
+func Lookup(name string) (Driver, bool) {
+	registryMu.RLock()
+	d, ok := registry[name]
+	registryMu.RUnlock()
+	if ok {
+		return d, true
+	}
+
+	if genericFallback && name != "" {
+		return genericDriver{name: name}, true
+	}
+	return nil, false
+}
+
+// RegisteredDrivers returns the sorted names of all registered drivers.
+func RegisteredDrivers() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// funcDriver is a Driver backed by a processing function.
+type funcDriver struct {
+	name    string
+	prefix  string
+	caps    Capability
+	process func(map[string]uint64) ProcessedStats
+}
+
+// NewDriver returns a Driver that processes statistics with the given function.
+func NewDriver(name, prefix string, caps Capability, process func(rawStats map[string]uint64) ProcessedStats) Driver {
+	return funcDriver{
+		name:    name,
+		prefix:  prefix,
+		caps:    caps,
+		process: process,
+	}
+}
+
+func (d funcDriver) Name() string             { return d.name }
+func (d funcDriver) Prefix() string           { return d.prefix }
+func (d funcDriver) Capabilities() Capability { return d.caps }
+
+func (d funcDriver) Process(rawStats map[string]uint64) ProcessedStats {
+	return d.process(rawStats)
+}
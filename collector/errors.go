@@ -0,0 +1,32 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// collectErrorStats exports errored and dropped packets by normalized reason.
+// Only the reasons the driver reports are exported.
+func (c *EthtoolCollector) collectErrorStats(ch chan<- prometheus.Metric, errorStats *drivers.ErrorStats, labels, labelValues []string) {
+	errorLabels := withLabel(labels, "direction", "reason")
+	desc := c.getOrCreateMetricDesc("errors_total", "Number of errored or dropped packets by normalized reason", errorLabels)
+
+	directions := []struct {
+		direction string
+		reasons   map[string]uint64
+	}{
+		{"rx", errorStats.Rx},
+		{"tx", errorStats.Tx},
+	}
+	for _, d := range directions {
+		for reason, value := range d.reasons {
+			ch <- prometheus.MustNewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(value),
+				withLabel(labelValues, d.direction, reason)...,
+			)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package collector
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultFeaturesInclude selects the offloads whose state most often affects
+// throughput: GRO, LRO, TSO, receive checksumming, RSS hashing, ntuple filters
+// and TC offload. Names are kernel feature strings (`ethtool -k` shows some
+// of them under longer aliases, e.g. rx-gro as generic-receive-offload).
+const DefaultFeaturesInclude = `^(rx-gro|rx-lro|tx-tcp6?-segmentation|rx-checksum|rx-hashing|rx-ntuple-filter|hw-tc-offload)$`
+
+// collectFeatures exports the state of the offload features of an interface.
+func (c *EthtoolCollector) collectFeatures(ch chan<- prometheus.Metric, ifaceName string, opts *Options, labels, labelValues []string) {
+	done := c.timeEthtool("feature_names")
+	indexes, err := c.ethtool.FeatureNames(ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get feature names for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	done = c.timeEthtool("features")
+	states, err := c.ioctl.Features(ifaceName, len(indexes))
+	done()
+	if err != nil {
+		log.Debugf("Failed to get features for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	names := make([]string, 0, len(indexes))
+	for name := range indexes {
+		if opts.FeaturesInclude != nil && !opts.FeaturesInclude.MatchString(name) {
+			continue
+		}
+		if opts.FeaturesExclude != nil && opts.FeaturesExclude.MatchString(name) {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	featureLabels := withLabel(labels, "feature")
+	for _, name := range names {
+		index := int(indexes[name])
+		if index >= len(states) {
+			continue
+		}
+		state := states[index]
+		featureLabelValues := withLabel(labelValues, name)
+
+		c.sendGauge(ch, "feature_enabled", "Whether an offload feature is currently active",
+			featureLabels, featureLabelValues, boolToFloat(state.Active))
+		c.sendGauge(ch, "feature_requested", "Whether an offload feature was requested to be active",
+			featureLabels, featureLabelValues, boolToFloat(state.Requested))
+		c.sendGauge(ch, "feature_fixed", "Whether an offload feature cannot be changed",
+			featureLabels, featureLabelValues, boolToFloat(!state.Available))
+	}
+}
@@ -0,0 +1,165 @@
+package collector
+
+import (
+	"sort"
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// fecModes names the ETHTOOL_FEC_*_BIT modes of struct ethtool_fecparam,
+// indexed by bit. Bit 0 (ETHTOOL_FEC_NONE_BIT) means the driver does not
+// report FEC modes.
+var fecModes = []string{
+	1: "auto",
+	2: "off",
+	3: "rs",
+	4: "baser",
+	5: "llrs",
+}
+
+// fecCounterHelp describes the counters of drivers.FECStats.
+var fecCounterHelp = map[string]string{
+	drivers.FECCorrectedBlocks:     "Number of FEC codewords with errors corrected",
+	drivers.FECUncorrectableBlocks: "Number of FEC codewords with errors that could not be corrected",
+	drivers.FECCorrectedBits:       "Number of bit errors corrected by FEC",
+	drivers.FECReceivedBits:        "Number of bits received at the physical layer",
+}
+
+// collectFECMode exports the configured and active FEC modes of an interface
+// (ETHTOOL_GFECPARAM). Interfaces whose driver lacks FEC support are skipped.
+func (c *EthtoolCollector) collectFECMode(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	done := c.timeEthtool("fec_param")
+	fec, err := c.ioctl.FECParam(ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get FEC parameters for interface %s: %v", ifaceName, err)
+		return
+	}
+	if fec.FEC&^1 == 0 && fec.ActiveFEC&^1 == 0 {
+		return
+	}
+
+	modeLabels := withLabel(labels, "mode")
+	for bit, mode := range fecModes {
+		if mode == "" {
+			continue
+		}
+		modeLabelValues := withLabel(labelValues, mode)
+		c.sendGauge(ch, "fec_mode_configured", "Whether a FEC mode is configured (several modes may be allowed)",
+			modeLabels, modeLabelValues, boolToFloat(fec.FEC&(1<<bit) != 0))
+		c.sendGauge(ch, "fec_mode_active", "Whether a FEC mode is active on the link",
+			modeLabels, modeLabelValues, boolToFloat(fec.ActiveFEC&(1<<bit) != 0))
+	}
+}
+
+// mergeFECStats combines the FEC counters mapped from the driver statistics
+// with those read over netlink. Each counter is taken, in total and per lane,
+// from the driver statistics if the mapping provides it and from netlink
+// otherwise. Either argument may be nil.
+func mergeFECStats(driver, standard *drivers.FECStats) *drivers.FECStats {
+	if standard == nil {
+		return driver
+	}
+	if driver == nil {
+		return standard
+	}
+
+	merged := &drivers.FECStats{
+		Total: make(map[string]uint64),
+		Lanes: make(map[int]map[string]uint64),
+	}
+	// Counters reported by the driver statistics, in total or on a lane.
+	fromDriver := make(map[string]bool)
+	for name := range driver.Total {
+		fromDriver[name] = true
+	}
+	for _, counters := range driver.Lanes {
+		for name := range counters {
+			fromDriver[name] = true
+		}
+	}
+
+	for _, source := range []struct {
+		stats  *drivers.FECStats
+		driver bool
+	}{{driver, true}, {standard, false}} {
+		for name, value := range source.stats.Total {
+			if fromDriver[name] == source.driver {
+				merged.Total[name] = value
+			}
+		}
+		for lane, counters := range source.stats.Lanes {
+			for name, value := range counters {
+				if fromDriver[name] != source.driver {
+					continue
+				}
+				if merged.Lanes[lane] == nil {
+					merged.Lanes[lane] = make(map[string]uint64)
+				}
+				merged.Lanes[lane][name] = value
+			}
+		}
+	}
+	return merged
+}
+
+// collectFECStats exports the FEC counters of an interface, in total and per
+// lane, and the pre-FEC bit error rate where the driver counts received bits.
+func (c *EthtoolCollector) collectFECStats(ch chan<- prometheus.Metric, stats *drivers.FECStats, labels, labelValues []string) {
+	for name, value := range stats.Total {
+		desc := c.getOrCreateMetricDesc("fec_"+name+"_total", fecCounterHelp[name], labels)
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(value),
+			labelValues...,
+		)
+	}
+
+	lanes := make([]int, 0, len(stats.Lanes))
+	for lane := range stats.Lanes {
+		lanes = append(lanes, lane)
+	}
+	sort.Ints(lanes)
+
+	laneLabels := withLabel(labels, "lane")
+	for _, lane := range lanes {
+		laneLabelValues := withLabel(labelValues, strconv.Itoa(lane))
+		for name, value := range stats.Lanes[lane] {
+			desc := c.getOrCreateMetricDesc("fec_lane_"+name+"_total", fecCounterHelp[name]+" on a lane", laneLabels)
+			ch <- prometheus.MustNewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(value),
+				laneLabelValues...,
+			)
+		}
+	}
+
+	// The rate is over the lifetime of the counters; rate() of the counters
+	// gives the current rate.
+	receivedBits, ok := stats.Total[drivers.FECReceivedBits]
+	if !ok || receivedBits == 0 {
+		return
+	}
+	if correctedBits, ok := stats.Total[drivers.FECCorrectedBits]; ok {
+		c.sendGauge(ch, "fec_pre_fec_ber", "Pre-FEC bit error rate since the counters were last reset",
+			labels, labelValues, float64(correctedBits)/float64(receivedBits))
+	}
+
+	// The driver does not count received bits per lane, so each lane is
+	// assumed to carry an equal share of them.
+	for _, lane := range lanes {
+		correctedBits, ok := stats.Lanes[lane][drivers.FECCorrectedBits]
+		if !ok {
+			continue
+		}
+		c.sendGauge(ch, "fec_lane_pre_fec_ber", "Pre-FEC bit error rate of a lane since the counters were last reset, assuming the lanes carry an equal share of the received bits",
+			laneLabels, withLabel(labelValues, strconv.Itoa(lane)),
+			float64(correctedBits)*float64(len(lanes))/float64(receivedBits))
+	}
+}
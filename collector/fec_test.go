@@ -0,0 +1,158 @@
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// fecArray encodes a FEC statistic array as the kernel does.
+func fecArray(values ...uint64) []byte {
+	var b []byte
+	for _, v := range values {
+		b = append(b, nlenc.Uint64Bytes(v)...)
+	}
+	return b
+}
+
+func TestDecodeFECStats(t *testing.T) {
+	tests := []struct {
+		name  string
+		stats map[uint16][]byte
+		want  *drivers.FECStats
+	}{
+		{
+			name: "totals and lanes",
+			stats: map[uint16][]byte{
+				ethtoolAFECStatCorrected: fecArray(30, 10, 20),
+				ethtoolAFECStatUncorr:    fecArray(3, 1, 2),
+				// The driver does not count corrected bits.
+				ethtoolAFECStatCorrBits: nil,
+			},
+			want: &drivers.FECStats{
+				Total: map[string]uint64{
+					drivers.FECCorrectedBlocks:     30,
+					drivers.FECUncorrectableBlocks: 3,
+				},
+				Lanes: map[int]map[string]uint64{
+					0: {drivers.FECCorrectedBlocks: 10, drivers.FECUncorrectableBlocks: 1},
+					1: {drivers.FECCorrectedBlocks: 20, drivers.FECUncorrectableBlocks: 2},
+				},
+			},
+		},
+		{
+			name: "totals only",
+			stats: map[uint16][]byte{
+				ethtoolAFECStatCorrected: fecArray(7),
+				ethtoolAFECStatCorrBits:  fecArray(99),
+			},
+			want: &drivers.FECStats{
+				Total: map[string]uint64{
+					drivers.FECCorrectedBlocks: 7,
+					drivers.FECCorrectedBits:   99,
+				},
+				Lanes: map[int]map[string]uint64{},
+			},
+		},
+		{
+			name: "no counters",
+			stats: map[uint16][]byte{
+				ethtoolAFECStatCorrected: nil,
+				ethtoolAFECStatUncorr:    nil,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ae := netlink.NewAttributeEncoder()
+			ae.Nested(ethtoolAFECHeader, func(nae *netlink.AttributeEncoder) error {
+				nae.String(1, "eth0")
+				return nil
+			})
+			ae.Uint32(4, 1<<3) // ETHTOOL_A_FEC_ACTIVE
+			ae.Nested(ethtoolAFECStats, func(nae *netlink.AttributeEncoder) error {
+				for attr, values := range tt.stats {
+					nae.Bytes(attr, values)
+				}
+				return nil
+			})
+			data, err := ae.Encode()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := decodeFECStats(data)
+			if err != nil {
+				t.Fatalf("decodeFECStats() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("decodeFECStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergeFECStats(t *testing.T) {
+	driver := &drivers.FECStats{
+		Total: map[string]uint64{
+			drivers.FECCorrectedBits: 500,
+			drivers.FECReceivedBits:  1e9,
+		},
+		Lanes: map[int]map[string]uint64{
+			0: {drivers.FECCorrectedBits: 200},
+			1: {drivers.FECCorrectedBits: 300},
+		},
+	}
+	standard := &drivers.FECStats{
+		Total: map[string]uint64{
+			drivers.FECCorrectedBlocks: 30,
+			drivers.FECCorrectedBits:   499,
+		},
+		Lanes: map[int]map[string]uint64{
+			0: {drivers.FECCorrectedBlocks: 10, drivers.FECCorrectedBits: 199},
+			1: {drivers.FECCorrectedBlocks: 20, drivers.FECCorrectedBits: 300},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		driver, standard *drivers.FECStats
+		want             *drivers.FECStats
+	}{
+		{name: "neither"},
+		{name: "driver only", driver: driver, want: driver},
+		{name: "netlink only", standard: standard, want: standard},
+		{
+			// Corrected bits come from the driver statistics in total and
+			// per lane; netlink adds the corrected blocks.
+			name:     "both",
+			driver:   driver,
+			standard: standard,
+			want: &drivers.FECStats{
+				Total: map[string]uint64{
+					drivers.FECCorrectedBlocks: 30,
+					drivers.FECCorrectedBits:   500,
+					drivers.FECReceivedBits:    1e9,
+				},
+				Lanes: map[int]map[string]uint64{
+					0: {drivers.FECCorrectedBlocks: 10, drivers.FECCorrectedBits: 200},
+					1: {drivers.FECCorrectedBlocks: 20, drivers.FECCorrectedBits: 300},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeFECStats(tt.driver, tt.standard)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeFECStats() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,331 @@
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// Ethtool netlink attributes not covered by golang.org/x/sys/unix, from
+// uapi/linux/ethtool_netlink.h.
+const (
+	ethtoolAStatsHeader = 2 // ETHTOOL_A_STATS_HEADER
+	ethtoolAStatsGroups = 3 // ETHTOOL_A_STATS_GROUPS
+	ethtoolAStatsGrp    = 4 // ETHTOOL_A_STATS_GRP
+
+	ethtoolAStatsGrpID     = 2 // ETHTOOL_A_STATS_GRP_ID
+	ethtoolAStatsGrpStat   = 4 // ETHTOOL_A_STATS_GRP_STAT
+	ethtoolAStatsGrpHistRx = 5 // ETHTOOL_A_STATS_GRP_HIST_RX
+	ethtoolAStatsGrpHistTx = 6 // ETHTOOL_A_STATS_GRP_HIST_TX
+
+	ethtoolAStatsGrpHistBktLow = 7 // ETHTOOL_A_STATS_GRP_HIST_BKT_LOW
+	ethtoolAStatsGrpHistBktHi  = 8 // ETHTOOL_A_STATS_GRP_HIST_BKT_HI
+	ethtoolAStatsGrpHistVal    = 9 // ETHTOOL_A_STATS_GRP_HIST_VAL
+
+	ethtoolAFECHeader = 1 // ETHTOOL_A_FEC_HEADER
+	ethtoolAFECStats  = 5 // ETHTOOL_A_FEC_STATS
+
+	ethtoolAFECStatCorrected = 2 // ETHTOOL_A_FEC_STAT_CORRECTED
+	ethtoolAFECStatUncorr    = 3 // ETHTOOL_A_FEC_STAT_UNCORR
+	ethtoolAFECStatCorrBits  = 4 // ETHTOOL_A_FEC_STAT_CORR_BITS
+)
+
+// fecStatCounters maps the ETHTOOL_A_FEC_STAT_* attributes to the counters
+// of drivers.FECStats.
+var fecStatCounters = map[uint16]string{
+	ethtoolAFECStatCorrected: drivers.FECCorrectedBlocks,
+	ethtoolAFECStatUncorr:    drivers.FECUncorrectableBlocks,
+	ethtoolAFECStatCorrBits:  drivers.FECCorrectedBits,
+}
+
+// netlinkBackend reads the standardized statistics groups, pause frame and
+// FEC statistics over the ethtool generic netlink family. The driver-specific
+// counters and driver information are only available through the ioctl.
+type netlinkBackend struct {
+	ioctlBackend
+
+	conn   *genetlink.Conn
+	family genetlink.Family
+
+	// statsUnsupported is set once the kernel rejected ETHTOOL_MSG_STATS_GET,
+	// which predates Linux 5.13, so that it is not asked again.
+	statsUnsupported atomic.Bool
+}
+
+func newNetlinkBackend(ioctl ioctlBackend) (*netlinkBackend, error) {
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial generic netlink: %v", err)
+	}
+
+	family, err := conn.GetFamily(unix.ETHTOOL_GENL_NAME)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to get ethtool netlink family: %v", err)
+	}
+
+	return &netlinkBackend{
+		ioctlBackend: ioctl,
+		conn:         conn,
+		family:       family,
+	}, nil
+}
+
+func (b *netlinkBackend) Name() string { return BackendNetlink }
+
+func (b *netlinkBackend) Close() error {
+	return b.conn.Close()
+}
+
+// StandardStats requests every standardized statistics group and the pause
+// frame and FEC statistics of an interface.
+func (b *netlinkBackend) StandardStats(intf string) (*standardStats, error) {
+	if b.statsUnsupported.Load() {
+		return nil, errStandardStatsUnsupported
+	}
+
+	stats := &standardStats{Counters: make(map[string]uint64)}
+	if err := b.readStatsGroups(intf, stats); err != nil {
+		if errors.Is(err, unix.EOPNOTSUPP) {
+			log.Infof("Kernel does not report standard statistics over netlink: %v", err)
+			b.statsUnsupported.Store(true)
+			return nil, errStandardStatsUnsupported
+		}
+		return nil, err
+	}
+
+	// Drivers without pause frame support reject the request.
+	if err := b.readPauseStats(intf, stats); err != nil {
+		log.Debugf("Failed to get pause statistics for interface %s: %v", intf, err)
+	}
+	// Likewise for drivers without FEC support.
+	if err := b.readFECStats(intf, stats); err != nil {
+		log.Debugf("Failed to get FEC statistics for interface %s: %v", intf, err)
+	}
+	return stats, nil
+}
+
+// execute sends an ethtool netlink request for an interface and returns the
+// attributes of the reply. encode adds the attributes following the request
+// header.
+func (b *netlinkBackend) execute(cmd uint8, headerAttr uint16, intf string, headerFlags uint32, encode func(*netlink.AttributeEncoder)) ([]byte, error) {
+	ae := netlink.NewAttributeEncoder()
+	ae.Nested(headerAttr, func(nae *netlink.AttributeEncoder) error {
+		nae.String(unix.ETHTOOL_A_HEADER_DEV_NAME, intf)
+		if headerFlags != 0 {
+			nae.Uint32(unix.ETHTOOL_A_HEADER_FLAGS, headerFlags)
+		}
+		return nil
+	})
+	if encode != nil {
+		encode(ae)
+	}
+	data, err := ae.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs, err := b.conn.Execute(genetlink.Message{
+		Header: genetlink.Header{
+			Command: cmd,
+			Version: b.family.Version,
+		},
+		Data: data,
+	}, b.family.ID, netlink.Request)
+	if err != nil {
+		return nil, err
+	}
+	if len(msgs) != 1 {
+		return nil, fmt.Errorf("expected 1 reply message, got %d", len(msgs))
+	}
+	return msgs[0].Data, nil
+}
+
+// readStatsGroups reads the eth-phy, eth-mac, eth-ctrl and rmon groups.
+func (b *netlinkBackend) readStatsGroups(intf string, stats *standardStats) error {
+	data, err := b.execute(unix.ETHTOOL_MSG_STATS_GET, ethtoolAStatsHeader, intf, 0, func(ae *netlink.AttributeEncoder) {
+		// Request all groups as a compact bitset without mask.
+		var groups uint32
+		for _, group := range standardStatGroups {
+			groups |= 1 << group.id
+		}
+		ae.Nested(ethtoolAStatsGroups, func(nae *netlink.AttributeEncoder) error {
+			nae.Flag(unix.ETHTOOL_A_BITSET_NOMASK, true)
+			nae.Uint32(unix.ETHTOOL_A_BITSET_SIZE, uint32(len(standardStatGroups)))
+			nae.Bytes(unix.ETHTOOL_A_BITSET_VALUE, nlenc.Uint32Bytes(groups))
+			return nil
+		})
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get standard statistics: %w", err)
+	}
+
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return err
+	}
+	for ad.Next() {
+		if ad.Type() == ethtoolAStatsGrp {
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				return decodeStatsGroup(nad, stats)
+			})
+		}
+	}
+	return ad.Err()
+}
+
+// decodeStatsGroup decodes an ETHTOOL_A_STATS_GRP nest into stats.
+func decodeStatsGroup(ad *netlink.AttributeDecoder, stats *standardStats) error {
+	id := -1
+	values := make(map[int]uint64)
+	var rx, tx []rmonBucket
+
+	for ad.Next() {
+		switch ad.Type() {
+		case ethtoolAStatsGrpID:
+			id = int(ad.Uint32())
+		case ethtoolAStatsGrpStat:
+			// Each stat nest holds a single attribute whose type is the
+			// index of the counter in its group.
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					values[int(nad.Type())] = nad.Uint64()
+				}
+				return nil
+			})
+		case ethtoolAStatsGrpHistRx, ethtoolAStatsGrpHistTx:
+			var bucket rmonBucket
+			ad.Nested(func(nad *netlink.AttributeDecoder) error {
+				for nad.Next() {
+					switch nad.Type() {
+					case ethtoolAStatsGrpHistBktLow:
+						bucket.Low = nad.Uint32()
+					case ethtoolAStatsGrpHistBktHi:
+						bucket.High = nad.Uint32()
+					case ethtoolAStatsGrpHistVal:
+						bucket.Count = nad.Uint64()
+					}
+				}
+				return nil
+			})
+			if ad.Type() == ethtoolAStatsGrpHistRx {
+				rx = append(rx, bucket)
+			} else {
+				tx = append(tx, bucket)
+			}
+		}
+	}
+	if err := ad.Err(); err != nil {
+		return err
+	}
+
+	for _, group := range standardStatGroups {
+		if group.id != id {
+			continue
+		}
+		for index, value := range values {
+			if index < len(group.counters) {
+				stats.Counters[group.prefix+group.counters[index].name] = value
+			}
+		}
+	}
+	stats.RxHistogram = append(stats.RxHistogram, rx...)
+	stats.TxHistogram = append(stats.TxHistogram, tx...)
+	return nil
+}
+
+// readPauseStats reads the pause frame counters.
+func (b *netlinkBackend) readPauseStats(intf string, stats *standardStats) error {
+	data, err := b.execute(unix.ETHTOOL_MSG_PAUSE_GET, unix.ETHTOOL_A_PAUSE_HEADER, intf, unix.ETHTOOL_FLAG_STATS, nil)
+	if err != nil {
+		return err
+	}
+
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return err
+	}
+	for ad.Next() {
+		if ad.Type() != unix.ETHTOOL_A_PAUSE_STATS {
+			continue
+		}
+		ad.Nested(func(nad *netlink.AttributeDecoder) error {
+			for nad.Next() {
+				switch nad.Type() {
+				case unix.ETHTOOL_A_PAUSE_STAT_TX_FRAMES:
+					stats.Counters[pauseTxFrames] = nad.Uint64()
+				case unix.ETHTOOL_A_PAUSE_STAT_RX_FRAMES:
+					stats.Counters[pauseRxFrames] = nad.Uint64()
+				}
+			}
+			return nil
+		})
+	}
+	return ad.Err()
+}
+
+// readFECStats reads the FEC counters.
+func (b *netlinkBackend) readFECStats(intf string, stats *standardStats) error {
+	data, err := b.execute(unix.ETHTOOL_MSG_FEC_GET, ethtoolAFECHeader, intf, unix.ETHTOOL_FLAG_STATS, nil)
+	if err != nil {
+		return err
+	}
+	stats.FEC, err = decodeFECStats(data)
+	return err
+}
+
+// decodeFECStats decodes the attributes of an ETHTOOL_MSG_FEC_GET reply. Each
+// counter is an array holding the total followed by the per-lane values, if
+// the driver reports lanes. It returns nil if the driver reports no counter.
+func decodeFECStats(data []byte) (*drivers.FECStats, error) {
+	ad, err := netlink.NewAttributeDecoder(data)
+	if err != nil {
+		return nil, err
+	}
+	fec := &drivers.FECStats{
+		Total: make(map[string]uint64),
+		Lanes: make(map[int]map[string]uint64),
+	}
+	for ad.Next() {
+		if ad.Type() != ethtoolAFECStats {
+			continue
+		}
+		ad.Nested(func(nad *netlink.AttributeDecoder) error {
+			for nad.Next() {
+				name, ok := fecStatCounters[nad.Type()]
+				if !ok {
+					continue
+				}
+				values := nad.Bytes()
+				if len(values) < 8 {
+					// The driver does not report this counter.
+					continue
+				}
+				fec.Total[name] = nlenc.Uint64(values[:8])
+				for lane := 0; len(values) >= 8*(lane+2); lane++ {
+					if fec.Lanes[lane] == nil {
+						fec.Lanes[lane] = make(map[string]uint64)
+					}
+					fec.Lanes[lane][name] = nlenc.Uint64(values[8*(lane+1) : 8*(lane+2)])
+				}
+			}
+			return nil
+		})
+	}
+	if err := ad.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(fec.Total) == 0 {
+		return nil, nil
+	}
+	return fec, nil
+}
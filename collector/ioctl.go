@@ -0,0 +1,196 @@
+package collector
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ethtool commands not covered by safchain/ethtool, from uapi/linux/ethtool.h.
+const (
+	ethtoolGRingParam = 0x00000010 // Get ring parameters
+	ethtoolGFeatures  = 0x0000003a // Get device offload settings
+	ethtoolGStrings   = 0x0000001b // Get specified string set
+	ethtoolGStats     = 0x0000001d // Get NIC-specific statistics
+	ethtoolGFECParam  = 0x00000050 // Get FEC settings
+
+	ethSSStats    = 1  // Statistics string set
+	ethGStringLen = 32 // Length of a string set entry
+
+	// maxGStrings bounds the number of statistics, as in safchain/ethtool.
+	// The kernel writes as many entries as the driver reports regardless of
+	// the buffer size, so buffers are always allocated for the maximum.
+	maxGStrings = 32768
+)
+
+// ethtoolRingParam mirrors struct ethtool_ringparam.
+type ethtoolRingParam struct {
+	Cmd               uint32
+	RxMaxPending      uint32
+	RxMiniMaxPending  uint32
+	RxJumboMaxPending uint32
+	TxMaxPending      uint32
+	RxPending         uint32
+	RxMiniPending     uint32
+	RxJumboPending    uint32
+	TxPending         uint32
+}
+
+// ethtoolFECParam mirrors struct ethtool_fecparam. ActiveFEC and FEC are
+// bitmasks of the ETHTOOL_FEC_*_BIT modes.
+type ethtoolFECParam struct {
+	Cmd       uint32
+	ActiveFEC uint32
+	FEC       uint32
+	Reserved  uint32
+}
+
+// featureState is the state of a single offload feature, decoded from a
+// struct ethtool_get_features_block.
+type featureState struct {
+	// Available is set when the feature can be changed.
+	Available bool
+	// Requested is the state last requested by the user.
+	Requested bool
+	// Active is the state the feature is currently in.
+	Active bool
+}
+
+// ethtoolGStringsBuffer mirrors struct ethtool_gstrings.
+type ethtoolGStringsBuffer struct {
+	Cmd       uint32
+	StringSet uint32
+	Len       uint32
+	Data      [maxGStrings * ethGStringLen]byte
+}
+
+// statsBuffer mirrors struct ethtool_stats. It is large, so callers reading
+// statistics repeatedly keep one around instead of allocating it per read.
+type statsBuffer struct {
+	Cmd    uint32
+	NStats uint32
+	Data   [maxGStrings]uint64
+}
+
+// ifreq mirrors struct ifreq with the ifr_data member.
+type ifreq struct {
+	name [unix.IFNAMSIZ]byte
+	data uintptr
+}
+
+// ethtoolIoctl issues SIOCETHTOOL requests that safchain/ethtool does not
+// provide.
+type ethtoolIoctl struct {
+	fd int
+}
+
+func newEthtoolIoctl() (*ethtoolIoctl, error) {
+	fd, err := unix.Socket(unix.AF_INET, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.IPPROTO_IP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ethtool socket: %v", err)
+	}
+	return &ethtoolIoctl{fd: fd}, nil
+}
+
+// Close closes the underlying socket.
+func (e *ethtoolIoctl) Close() error {
+	return unix.Close(e.fd)
+}
+
+// request sends an ethtool command whose payload starts at data.
+func (e *ethtoolIoctl) request(ifaceName string, data unsafe.Pointer) error {
+	var ifr ifreq
+	copy(ifr.name[:unix.IFNAMSIZ-1], ifaceName)
+	ifr.data = uintptr(data)
+
+	_, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(e.fd), unix.SIOCETHTOOL, uintptr(unsafe.Pointer(&ifr)))
+	runtime.KeepAlive(data)
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// RingParam returns the current and maximum ring sizes of an interface.
+func (e *ethtoolIoctl) RingParam(ifaceName string) (ethtoolRingParam, error) {
+	ring := ethtoolRingParam{Cmd: ethtoolGRingParam}
+	if err := e.request(ifaceName, unsafe.Pointer(&ring)); err != nil {
+		return ethtoolRingParam{}, err
+	}
+	return ring, nil
+}
+
+// FECParam returns the configured and active FEC modes of an interface.
+func (e *ethtoolIoctl) FECParam(ifaceName string) (ethtoolFECParam, error) {
+	fec := ethtoolFECParam{Cmd: ethtoolGFECParam}
+	if err := e.request(ifaceName, unsafe.Pointer(&fec)); err != nil {
+		return ethtoolFECParam{}, err
+	}
+	return fec, nil
+}
+
+// Features returns the state of the offload features of an interface.
+// Feature n of count is described by bit n%32 of block n/32.
+func (e *ethtoolIoctl) Features(ifaceName string, count int) ([]featureState, error) {
+	blocks := (count + 31) / 32
+
+	// struct ethtool_gfeatures is a command, a block count and blocks of
+	// available, requested, active and never_changed bitmaps.
+	buf := make([]uint32, 2+4*blocks)
+	buf[0] = ethtoolGFeatures
+	buf[1] = uint32(blocks)
+	if err := e.request(ifaceName, unsafe.Pointer(&buf[0])); err != nil {
+		return nil, err
+	}
+
+	states := make([]featureState, count)
+	for i := range states {
+		block := buf[2+4*(i/32):]
+		bit := uint32(1) << (i % 32)
+		states[i] = featureState{
+			Available: block[0]&bit != 0,
+			Requested: block[1]&bit != 0,
+			Active:    block[2]&bit != 0,
+		}
+	}
+	return states, nil
+}
+
+// StatNames returns the names of the ethtool statistics of an interface, in
+// the order StatValues reports their values.
+func (e *ethtoolIoctl) StatNames(ifaceName string) ([]string, error) {
+	buf := &ethtoolGStringsBuffer{Cmd: ethtoolGStrings, StringSet: ethSSStats}
+	if err := e.request(ifaceName, unsafe.Pointer(buf)); err != nil {
+		return nil, err
+	}
+
+	if buf.Len > maxGStrings {
+		return nil, fmt.Errorf("interface %s reports %d statistics, more than the supported %d", ifaceName, buf.Len, maxGStrings)
+	}
+	names := make([]string, buf.Len)
+	for i := range names {
+		entry := buf.Data[i*ethGStringLen : (i+1)*ethGStringLen]
+		if end := bytes.IndexByte(entry, 0); end >= 0 {
+			entry = entry[:end]
+		}
+		names[i] = string(entry)
+	}
+	return names, nil
+}
+
+// StatValues reads the ethtool statistics of an interface into buf and
+// returns the values, which alias buf.
+func (e *ethtoolIoctl) StatValues(ifaceName string, buf *statsBuffer) ([]uint64, error) {
+	buf.Cmd = ethtoolGStats
+	buf.NStats = 0
+	if err := e.request(ifaceName, unsafe.Pointer(buf)); err != nil {
+		return nil, err
+	}
+	if buf.NStats > maxGStrings {
+		return nil, fmt.Errorf("interface %s reports %d statistics, more than the supported %d", ifaceName, buf.NStats, maxGStrings)
+	}
+	return buf.Data[:buf.NStats], nil
+}
@@ -0,0 +1,154 @@
+package collector
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+	"github.com/vishvananda/netlink"
+)
+
+// sysClassNet is where the kernel exposes per-interface attributes such as
+// carrier state and carrier change counts.
+const sysClassNet = "/sys/class/net"
+
+// Values of struct ethtool_cmd fields, from uapi/linux/ethtool.h.
+const (
+	duplexHalf = 0x00
+	duplexFull = 0x01
+
+	portTP    = 0x00
+	portAUI   = 0x01
+	portBNC   = 0x02
+	portMII   = 0x03
+	portFibre = 0x04
+	portDA    = 0x05
+	portNone  = 0xef
+	portOther = 0xff
+)
+
+var portNames = map[uint8]string{
+	portTP:    "tp",
+	portAUI:   "aui",
+	portBNC:   "bnc",
+	portMII:   "mii",
+	portFibre: "fibre",
+	portDA:    "da",
+	portNone:  "none",
+	portOther: "other",
+}
+
+// collectLinkSettings exports negotiated link settings, operational state and
+// carrier state of an interface.
+func (c *EthtoolCollector) collectLinkSettings(ch chan<- prometheus.Metric, link netlink.Link, labels, labelValues []string) {
+	ifaceName := link.Attrs().Name
+
+	var cmd ethtool.EthtoolCmd
+	done := c.timeEthtool("link_settings")
+	speed, err := c.ethtool.CmdGet(&cmd, ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get link settings for interface %s: %v", ifaceName, err)
+	} else {
+		// The kernel reports SPEED_UNKNOWN (-1) while the link is down.
+		if speed != 0 && speed != math.MaxUint32 {
+			c.sendGauge(ch, "link_speed_bits_per_second", "Negotiated link speed in bits per second",
+				labels, labelValues, float64(speed)*1e6)
+		}
+
+		duplex := "unknown"
+		switch cmd.Duplex {
+		case duplexHalf:
+			duplex = "half"
+		case duplexFull:
+			duplex = "full"
+		}
+		c.sendGauge(ch, "link_duplex", "Negotiated link duplex (constant 1)",
+			withLabel(labels, "duplex"), withLabel(labelValues, duplex), 1)
+
+		c.sendGauge(ch, "link_autoneg", "Whether link autonegotiation is enabled",
+			labels, labelValues, boolToFloat(cmd.Autoneg != 0))
+
+		port, ok := portNames[cmd.Port]
+		if !ok {
+			port = "other"
+		}
+		c.sendGauge(ch, "link_port", "Physical port type (constant 1)",
+			withLabel(labels, "port"), withLabel(labelValues, port), 1)
+	}
+
+	c.sendGauge(ch, "link_oper_state", "Operational state as reported by netlink (constant 1)",
+		withLabel(labels, "state"), withLabel(labelValues, link.Attrs().OperState.String()), 1)
+
+	if carrier, ok := readSysfsUint(ifaceName, "carrier"); ok {
+		c.sendGauge(ch, "link_carrier", "Whether the interface has carrier",
+			labels, labelValues, float64(carrier))
+	}
+
+	carrierChanges := []struct {
+		file, name, help string
+	}{
+		{"carrier_changes", "link_carrier_changes_total", "Number of carrier state changes"},
+		{"carrier_up_count", "link_carrier_up_total", "Number of times the carrier went up"},
+		{"carrier_down_count", "link_carrier_down_total", "Number of times the carrier went down"},
+	}
+	for _, cc := range carrierChanges {
+		value, ok := readSysfsUint(ifaceName, cc.file)
+		if !ok {
+			continue
+		}
+		desc := c.getOrCreateMetricDesc(cc.name, cc.help, labels)
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(value),
+			labelValues...,
+		)
+	}
+}
+
+// sendGauge sends a gauge metric, creating its description if needed.
+func (c *EthtoolCollector) sendGauge(ch chan<- prometheus.Metric, name, help string, labels, labelValues []string, value float64) {
+	desc := c.getOrCreateMetricDesc(name, help, labels)
+	ch <- prometheus.MustNewConstMetric(
+		desc,
+		prometheus.GaugeValue,
+		value,
+		labelValues...,
+	)
+}
+
+// withLabel returns a copy of labels with an extra element appended, leaving
+// the original slice untouched.
+func withLabel(labels []string, extra ...string) []string {
+	result := make([]string, 0, len(labels)+len(extra))
+	result = append(result, labels...)
+	return append(result, extra...)
+}
+
+// readSysfsUint reads an unsigned integer attribute of an interface from
+// sysfs. Attributes that are missing or unreadable (e.g. carrier while the
+// interface is administratively down) report false.
+func readSysfsUint(ifaceName, attr string) (uint64, bool) {
+	data, err := os.ReadFile(filepath.Join(sysClassNet, ifaceName, attr))
+	if err != nil {
+		return 0, false
+	}
+	value, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return value, true
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,472 @@
+package collector
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// DefaultMicroburstCounters selects the raw counters that feed the byte and
+// drop metrics of the built-in drivers and the generic fallback: every source
+// of their interface-wide byte and drop counters, and their per-queue byte
+// counters.
+const DefaultMicroburstCounters = `^(` +
+	`(rx|tx)_(bytes|good_bytes|total_bytes)|` +
+	`rx_(dropped|drops|discards|out_of_buffer|buff_alloc_err|wqe_err|alloc_fail|pg_alloc_fail|missed_errors|no_buffer_count|no_dma_resources)|` +
+	`alloc_rx_(page|buff)_failed|` +
+	`tx_(dropped|drops|discards|cqe_err|errors|dropped_link_down\.nic)|` +
+	`(rx|tx)\d+_bytes|(rx|tx)_queue_\d+_bytes|(rx|tx)-\d+\.bytes|queue_\d+_(rx|tx)_bytes` +
+	`)$`
+
+// MinMicroburstInterval is the shortest supported microburst sampling
+// interval.
+const MinMicroburstInterval = 10 * time.Millisecond
+
+// windowSlots is the number of slots a sliding window maximum is split into.
+const windowSlots = 10
+
+// microburstRateBuckets are the histogram buckets of per-interval byte
+// rates, from 100 kB/s to about 26 GB/s (above 200 Gbit/s).
+var microburstRateBuckets = prometheus.ExponentialBuckets(1e5, 4, 10)
+
+// windowMax tracks the maximum of a value over a sliding window. The window
+// is split into slots, so values expire one slot at a time.
+type windowMax struct {
+	slot   time.Duration
+	values [windowSlots]float64
+	epochs [windowSlots]int64
+}
+
+func newWindowMax(window time.Duration) *windowMax {
+	return &windowMax{slot: window / windowSlots}
+}
+
+func (w *windowMax) observe(t time.Time, value float64) {
+	epoch := t.UnixNano() / int64(w.slot)
+	i := epoch % windowSlots
+	if w.epochs[i] != epoch {
+		w.epochs[i] = epoch
+		w.values[i] = value
+		return
+	}
+	if value > w.values[i] {
+		w.values[i] = value
+	}
+}
+
+// max returns the maximum observed within the window ending at t.
+func (w *windowMax) max(t time.Time) float64 {
+	epoch := t.UnixNano() / int64(w.slot)
+	var result float64
+	for i := range w.values {
+		if epoch-w.epochs[i] < windowSlots && w.values[i] > result {
+			result = w.values[i]
+		}
+	}
+	return result
+}
+
+// rateHistogram accumulates per-interval rates into microburstRateBuckets.
+type rateHistogram struct {
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (h *rateHistogram) observe(value float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(microburstRateBuckets))
+	}
+	h.count++
+	h.sum += value
+	for i, bound := range microburstRateBuckets {
+		if value <= bound {
+			h.counts[i]++
+			return
+		}
+	}
+}
+
+// cumulative returns the cumulative bucket counts for a const histogram.
+func (h *rateHistogram) cumulative() map[float64]uint64 {
+	buckets := make(map[float64]uint64, len(microburstRateBuckets))
+	var total uint64
+	for i, bound := range microburstRateBuckets {
+		if h.counts != nil {
+			total += h.counts[i]
+		}
+		buckets[bound] = total
+	}
+	return buckets
+}
+
+// burstState holds the rate maxima and histograms of an interface.
+type burstState struct {
+	observed bool
+	prevTime time.Time
+	prev     drivers.ProcessedStats
+
+	rxBytes, txBytes *windowMax
+	rxDrops, txDrops *windowMax
+	queueRxBytes     map[int]*windowMax
+	queueTxBytes     map[int]*windowMax
+	rxHist, txHist   rateHistogram
+	queueRxHist      map[int]*rateHistogram
+	queueTxHist      map[int]*rateHistogram
+}
+
+func newBurstState(window time.Duration) *burstState {
+	return &burstState{
+		rxBytes:      newWindowMax(window),
+		txBytes:      newWindowMax(window),
+		rxDrops:      newWindowMax(window),
+		txDrops:      newWindowMax(window),
+		queueRxBytes: make(map[int]*windowMax),
+		queueTxBytes: make(map[int]*windowMax),
+		queueRxHist:  make(map[int]*rateHistogram),
+		queueTxHist:  make(map[int]*rateHistogram),
+	}
+}
+
+// burstSampler reads a whitelisted subset of the ethtool counters of a set of
+// interfaces at a high frequency, tracking the highest per-interval rates and
+// their distribution. Each interface has its own sampling goroutine and
+// buffer, so the interval holds for every interface as long as its own
+// driver answers in time. Counter names are resolved once per interface,
+// keeping the cost of each sample to a single ioctl.
+type burstSampler struct {
+	interfaces InterfaceSource
+	interval   time.Duration
+	window     time.Duration
+	counters   *regexp.Regexp
+	ethtool    *ethtool.Ethtool
+	ioctl      *ethtoolIoctl
+
+	mu     sync.Mutex
+	states map[string]*burstState
+
+	// workers holds a stop channel per sampled interface. It is only used
+	// by run.
+	workers map[string]chan struct{}
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newBurstSampler starts sampling the counters matching counters every
+// interval, tracking maximum rates over window.
+func newBurstSampler(interfaces InterfaceSource, interval, window time.Duration, counters *regexp.Regexp) (*burstSampler, error) {
+	if interval < MinMicroburstInterval {
+		return nil, fmt.Errorf("microburst interval %v is shorter than the minimum %v", interval, MinMicroburstInterval)
+	}
+	if window < windowSlots*interval {
+		return nil, fmt.Errorf("microburst window %v must span at least %d intervals", window, windowSlots)
+	}
+
+	eth, err := ethtool.NewEthtool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ethtool: %v", err)
+	}
+	ioctl, err := newEthtoolIoctl()
+	if err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	s := &burstSampler{
+		interfaces: interfaces,
+		interval:   interval,
+		window:     window,
+		counters:   counters,
+		ethtool:    eth,
+		ioctl:      ioctl,
+		states:     make(map[string]*burstState),
+		workers:    make(map[string]chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Close stops sampling and releases the sampler's resources.
+func (s *burstSampler) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.ethtool.Close()
+	return s.ioctl.Close()
+}
+
+// run starts and stops the interface samplers as interfaces come and go.
+func (s *burstSampler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.syncWorkers()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.syncWorkers()
+		}
+	}
+}
+
+// syncWorkers starts sampling new interfaces and stops sampling and forgets
+// the interfaces that disappeared.
+func (s *burstSampler) syncWorkers() {
+	names := s.interfaces.Interfaces()
+	current := make(map[string]struct{}, len(names))
+
+	for _, name := range names {
+		current[name] = struct{}{}
+		if _, ok := s.workers[name]; ok {
+			continue
+		}
+		stop := make(chan struct{})
+		s.workers[name] = stop
+		s.wg.Add(1)
+		go s.sampleInterface(name, stop)
+	}
+
+	for name, stop := range s.workers {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		close(stop)
+		delete(s.workers, name)
+
+		s.mu.Lock()
+		delete(s.states, name)
+		s.mu.Unlock()
+	}
+}
+
+// sampleInterface samples an interface every interval until stop or the
+// sampler is closed.
+func (s *burstSampler) sampleInterface(ifaceName string, stop <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	iface := &sampledInterface{name: ifaceName, buf: &statsBuffer{}}
+	for {
+		processed, now, err := s.read(iface)
+		if err != nil {
+			log.Debugf("Failed to sample microbursts of interface %s: %v", ifaceName, err)
+		} else {
+			s.mu.Lock()
+			select {
+			case <-stop:
+				// The interface is gone and its state was dropped.
+				s.mu.Unlock()
+				return
+			default:
+			}
+			state, ok := s.states[ifaceName]
+			if !ok {
+				state = newBurstState(s.window)
+				s.states[ifaceName] = state
+			}
+			s.update(state, now, processed)
+			state.prevTime = now
+			state.prev = processed
+			s.mu.Unlock()
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve looks up the driver and counter names of an interface and the
+// positions of the whitelisted counters.
+func (s *burstSampler) resolve(iface *sampledInterface) error {
+	iface.names = nil
+
+	info, err := s.ethtool.DriverInfo(iface.name)
+	if err != nil {
+		return fmt.Errorf("failed to get driver info: %v", err)
+	}
+	names, err := s.ioctl.StatNames(iface.name)
+	if err != nil {
+		return fmt.Errorf("failed to get statistic names: %v", err)
+	}
+
+	iface.indexes = iface.indexes[:0]
+	for i, name := range names {
+		if s.counters.MatchString(name) {
+			iface.indexes = append(iface.indexes, i)
+		}
+	}
+	if len(iface.indexes) == 0 {
+		log.Debugf("No microburst counters of interface %s match the whitelist", iface.name)
+	}
+	iface.driver = info.Driver
+	iface.names = names
+	return nil
+}
+
+// read reads the whitelisted counters of an interface and maps them through
+// its driver. Like sampler.read, it resolves the names again after a failed
+// read or when the number of statistics changes.
+func (s *burstSampler) read(iface *sampledInterface) (drivers.ProcessedStats, time.Time, error) {
+	if iface.names == nil {
+		if err := s.resolve(iface); err != nil {
+			return drivers.ProcessedStats{}, time.Time{}, err
+		}
+	}
+
+	values, err := s.ioctl.StatValues(iface.name, iface.buf)
+	if err != nil {
+		iface.names = nil
+		return drivers.ProcessedStats{}, time.Time{}, fmt.Errorf("failed to get statistics: %v", err)
+	}
+	now := time.Now()
+
+	if len(values) != len(iface.names) {
+		if err := s.resolve(iface); err != nil {
+			return drivers.ProcessedStats{}, time.Time{}, err
+		}
+		if len(values) != len(iface.names) {
+			return drivers.ProcessedStats{}, time.Time{}, fmt.Errorf("statistics changed while resolving their names")
+		}
+	}
+
+	rawStats := make(map[string]uint64, len(iface.indexes))
+	for _, i := range iface.indexes {
+		rawStats[iface.names[i]] = values[i]
+	}
+	return drivers.ProcessDriverStats(iface.driver, rawStats), now, nil
+}
+
+// update records the rates between the previous and the current sample.
+func (s *burstSampler) update(state *burstState, now time.Time, cur drivers.ProcessedStats) {
+	if state.prevTime.IsZero() {
+		return
+	}
+	seconds := now.Sub(state.prevTime).Seconds()
+	if seconds <= 0 {
+		return
+	}
+	state.observed = true
+
+	prev := state.prev.Basic
+	rxRate := counterRate(prev.RxBytes, cur.Basic.RxBytes, seconds)
+	txRate := counterRate(prev.TxBytes, cur.Basic.TxBytes, seconds)
+	state.rxBytes.observe(now, rxRate)
+	state.txBytes.observe(now, txRate)
+	state.rxDrops.observe(now, counterRate(prev.RxDrops, cur.Basic.RxDrops, seconds))
+	state.txDrops.observe(now, counterRate(prev.TxDrops, cur.Basic.TxDrops, seconds))
+	state.rxHist.observe(rxRate)
+	state.txHist.observe(txRate)
+
+	prevQueues := make(map[int]drivers.QueueStats, len(state.prev.PerQueue))
+	for _, q := range state.prev.PerQueue {
+		prevQueues[q.QueueIndex] = q
+	}
+	for _, q := range cur.PerQueue {
+		p, ok := prevQueues[q.QueueIndex]
+		if !ok {
+			continue
+		}
+		if state.queueRxBytes[q.QueueIndex] == nil {
+			state.queueRxBytes[q.QueueIndex] = newWindowMax(s.window)
+			state.queueTxBytes[q.QueueIndex] = newWindowMax(s.window)
+			state.queueRxHist[q.QueueIndex] = &rateHistogram{}
+			state.queueTxHist[q.QueueIndex] = &rateHistogram{}
+		}
+		queueRxRate := counterRate(p.RxBytes, q.RxBytes, seconds)
+		queueTxRate := counterRate(p.TxBytes, q.TxBytes, seconds)
+		state.queueRxBytes[q.QueueIndex].observe(now, queueRxRate)
+		state.queueTxBytes[q.QueueIndex].observe(now, queueTxRate)
+		state.queueRxHist[q.QueueIndex].observe(queueRxRate)
+		state.queueTxHist[q.QueueIndex].observe(queueTxRate)
+	}
+}
+
+// collectMicrobursts exports the microburst metrics of an interface.
+func (c *EthtoolCollector) collectMicrobursts(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	s := c.bursts
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state, ok := s.states[ifaceName]
+	if !ok || !state.observed {
+		return
+	}
+	now := time.Now()
+
+	maxima := []struct {
+		name, help string
+		window     *windowMax
+	}{
+		{"microburst_rx_bytes_per_second_max", "Highest received bytes per second over one microburst sampling interval within the window", state.rxBytes},
+		{"microburst_tx_bytes_per_second_max", "Highest transmitted bytes per second over one microburst sampling interval within the window", state.txBytes},
+		{"microburst_rx_drops_per_second_max", "Highest dropped received packets per second over one microburst sampling interval within the window", state.rxDrops},
+		{"microburst_tx_drops_per_second_max", "Highest dropped transmitted packets per second over one microburst sampling interval within the window", state.txDrops},
+	}
+	for _, m := range maxima {
+		c.sendGauge(ch, m.name, m.help, labels, labelValues, m.window.max(now))
+	}
+
+	queues := make([]int, 0, len(state.queueRxBytes))
+	for queue := range state.queueRxBytes {
+		queues = append(queues, queue)
+	}
+	sort.Ints(queues)
+	queueLabels := withLabel(labels, "queue")
+	for _, queue := range queues {
+		queueLabelValues := withLabel(labelValues, strconv.Itoa(queue))
+		c.sendGauge(ch, "microburst_queue_rx_bytes_per_second_max",
+			"Highest received bytes per second on a queue over one microburst sampling interval within the window",
+			queueLabels, queueLabelValues, state.queueRxBytes[queue].max(now))
+		c.sendGauge(ch, "microburst_queue_tx_bytes_per_second_max",
+			"Highest transmitted bytes per second on a queue over one microburst sampling interval within the window",
+			queueLabels, queueLabelValues, state.queueTxBytes[queue].max(now))
+
+		queueHistograms := []struct {
+			name, help string
+			hist       *rateHistogram
+		}{
+			{"microburst_queue_rx_bytes_per_second", "Distribution of received bytes per second on a queue over microburst sampling intervals", state.queueRxHist[queue]},
+			{"microburst_queue_tx_bytes_per_second", "Distribution of transmitted bytes per second on a queue over microburst sampling intervals", state.queueTxHist[queue]},
+		}
+		for _, h := range queueHistograms {
+			desc := c.getOrCreateMetricDesc(h.name, h.help, queueLabels)
+			ch <- prometheus.MustNewConstHistogram(desc, h.hist.count, h.hist.sum, h.hist.cumulative(), queueLabelValues...)
+		}
+	}
+
+	histograms := []struct {
+		name, help string
+		hist       *rateHistogram
+	}{
+		{"microburst_rx_bytes_per_second", "Distribution of received bytes per second over microburst sampling intervals", &state.rxHist},
+		{"microburst_tx_bytes_per_second", "Distribution of transmitted bytes per second over microburst sampling intervals", &state.txHist},
+	}
+	for _, h := range histograms {
+		desc := c.getOrCreateMetricDesc(h.name, h.help, labels)
+		ch <- prometheus.MustNewConstHistogram(desc, h.hist.count, h.hist.sum, h.hist.cumulative(), labelValues...)
+	}
+}
@@ -0,0 +1,211 @@
+package collector
+
+import (
+	"math"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+func TestWindowMax(t *testing.T) {
+	start := time.Unix(1000, 0)
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	// A 1s window has 100ms slots.
+	w := newWindowMax(time.Second)
+	w.observe(at(0), 5)
+	w.observe(at(50), 3)
+	w.observe(at(300), 8)
+	w.observe(at(350), 2)
+
+	tests := []struct {
+		t    time.Time
+		want float64
+	}{
+		{at(400), 8},
+		{at(999), 8},
+		// The slot of the 5 expires first, but 8 is still the maximum.
+		{at(1000), 8},
+		{at(1299), 8},
+		// Both slots have expired.
+		{at(1400), 0},
+	}
+	for _, tt := range tests {
+		if got := w.max(tt.t); got != tt.want {
+			t.Errorf("max at %v = %v, want %v", tt.t.Sub(start), got, tt.want)
+		}
+	}
+
+	// A slot reused after a full window starts over instead of keeping the
+	// old maximum.
+	w.observe(at(1300), 1)
+	if got := w.max(at(1350)); got != 1 {
+		t.Errorf("max after slot reuse = %v, want 1", got)
+	}
+}
+
+func TestRateHistogram(t *testing.T) {
+	var h rateHistogram
+	if got := h.cumulative()[microburstRateBuckets[0]]; got != 0 {
+		t.Errorf("empty histogram first bucket = %d, want 0", got)
+	}
+
+	values := []float64{5e4, 1e5, 3e5, 1e12}
+	for _, v := range values {
+		h.observe(v)
+	}
+	if h.count != uint64(len(values)) {
+		t.Errorf("count = %d, want %d", h.count, len(values))
+	}
+	if want := 5e4 + 1e5 + 3e5 + 1e12; h.sum != want {
+		t.Errorf("sum = %v, want %v", h.sum, want)
+	}
+
+	buckets := h.cumulative()
+	// 5e4 and 1e5 are at most the first bound, 3e5 is in the second
+	// (4e5), and 1e12 is above every bound and only counted in +Inf.
+	wantCumulative := map[float64]uint64{1e5: 2, 4e5: 3}
+	for bound, count := range buckets {
+		want, ok := wantCumulative[bound]
+		if !ok {
+			want = 3
+		}
+		if count != want {
+			t.Errorf("bucket %g = %d, want %d", bound, count, want)
+		}
+	}
+}
+
+func queueStats(rxBytes, txBytes uint64) drivers.ProcessedStats {
+	return drivers.ProcessedStats{
+		Basic: drivers.BasicStats{RxBytes: rxBytes, TxBytes: txBytes},
+		PerQueue: []drivers.QueueStats{
+			{QueueIndex: 0, RxBytes: rxBytes / 2, TxBytes: txBytes},
+			{QueueIndex: 1, RxBytes: rxBytes / 2},
+		},
+	}
+}
+
+func TestBurstSamplerUpdate(t *testing.T) {
+	s := &burstSampler{window: time.Second}
+	state := newBurstState(s.window)
+
+	start := time.Unix(1000, 0)
+	samples := []struct {
+		t     time.Time
+		stats drivers.ProcessedStats
+	}{
+		{start, queueStats(0, 0)},
+		// 10 MB in 10ms is 1 GB/s.
+		{start.Add(10 * time.Millisecond), queueStats(10e6, 1e6)},
+		// A counter reset counts as no traffic.
+		{start.Add(20 * time.Millisecond), queueStats(0, 0)},
+	}
+	for _, smp := range samples {
+		s.update(state, smp.t, smp.stats)
+		state.prevTime = smp.t
+		state.prev = smp.stats
+	}
+
+	if !state.observed {
+		t.Fatal("state not marked observed")
+	}
+	now := samples[len(samples)-1].t
+	checks := []struct {
+		name      string
+		got, want float64
+	}{
+		{"rx max", state.rxBytes.max(now), 1e9},
+		{"tx max", state.txBytes.max(now), 1e8},
+		{"queue 0 rx max", state.queueRxBytes[0].max(now), 5e8},
+		{"queue 1 rx max", state.queueRxBytes[1].max(now), 5e8},
+		{"queue 0 tx max", state.queueTxBytes[0].max(now), 1e8},
+		{"queue 1 tx max", state.queueTxBytes[1].max(now), 0},
+		{"rx histogram sum", state.rxHist.sum, 1e9},
+		{"queue 0 rx histogram sum", state.queueRxHist[0].sum, 5e8},
+		{"queue 1 tx histogram sum", state.queueTxHist[1].sum, 0},
+	}
+	for _, c := range checks {
+		if math.Abs(c.got-c.want) > 1e-3 {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	for queue, hist := range state.queueRxHist {
+		if hist.count != 2 {
+			t.Errorf("queue %d rx histogram count = %d, want 2", queue, hist.count)
+		}
+	}
+}
+
+func TestCollectMicroburstsQueueHistograms(t *testing.T) {
+	c := newTestCollector(Options{})
+	state := &burstState{
+		observed:     true,
+		rxBytes:      newWindowMax(time.Second),
+		txBytes:      newWindowMax(time.Second),
+		rxDrops:      newWindowMax(time.Second),
+		txDrops:      newWindowMax(time.Second),
+		queueRxBytes: map[int]*windowMax{3: newWindowMax(time.Second)},
+		queueTxBytes: map[int]*windowMax{3: newWindowMax(time.Second)},
+		queueRxHist:  map[int]*rateHistogram{3: {}},
+		queueTxHist:  map[int]*rateHistogram{3: {}},
+	}
+	state.queueRxHist[3].observe(2e5)
+	state.queueRxHist[3].observe(3e5)
+	c.bursts = &burstSampler{states: map[string]*burstState{"eth0": state}}
+
+	metrics := collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.collectMicrobursts(ch, "eth0", []string{"interface", "driver"}, []string{"eth0", "ice"})
+	})
+
+	m, ok := findMetric(metrics, "nic_microburst_queue_rx_bytes_per_second", map[string]string{"queue": "3"})
+	if !ok || m.value != 2 {
+		t.Errorf("queue rx histogram: got %+v (found %v), want 2 samples", m, ok)
+	}
+	if _, ok := findMetric(metrics, "nic_microburst_queue_tx_bytes_per_second", map[string]string{"queue": "3"}); !ok {
+		t.Error("queue tx histogram not exported")
+	}
+}
+
+func TestDefaultMicroburstCounters(t *testing.T) {
+	counters := regexp.MustCompile(DefaultMicroburstCounters)
+
+	mappings := map[string]map[string][]string{
+		drivers.DriverMLX5:  drivers.MLX5MetricMapping,
+		drivers.DriverICE:   drivers.ICEMetricMapping,
+		drivers.DriverI40E:  drivers.I40EMetricMapping,
+		drivers.DriverIXGBE: drivers.IXGBEMetricMapping,
+		"generic":           drivers.GenericMetricMapping,
+	}
+	for driver, mapping := range mappings {
+		for _, counter := range []string{
+			drivers.CounterRxBytes, drivers.CounterTxBytes,
+			drivers.CounterRxDrops, drivers.CounterTxDrops,
+		} {
+			for _, source := range mapping[counter] {
+				if !counters.MatchString(source) {
+					t.Errorf("%s source %q of %s is not in the default whitelist", driver, source, counter)
+				}
+			}
+		}
+	}
+
+	// Per-queue byte counters of the built-in drivers and the generic
+	// fallback.
+	for _, name := range []string{"rx3_bytes", "tx3_bytes", "rx_queue_3_bytes", "tx-3.bytes", "queue_3_rx_bytes"} {
+		if !counters.MatchString(name) {
+			t.Errorf("queue counter %q is not in the default whitelist", name)
+		}
+	}
+	for _, name := range []string{"rx_packets", "rx3_packets", "rx_crc_errors_phy"} {
+		if counters.MatchString(name) {
+			t.Errorf("counter %q is in the default whitelist", name)
+		}
+	}
+}
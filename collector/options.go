@@ -0,0 +1,176 @@
+package collector
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Options are the settings of an EthtoolCollector that can be changed while
+// it runs, see EthtoolCollector.SetOptions.
+type Options struct {
+	// DriverStats enables export of the raw driver-specific counters.
+	DriverStats bool
+	// DriverStatsInclude, if set, limits driver-specific export to the
+	// ethtool counter names it matches.
+	DriverStatsInclude *regexp.Regexp
+	// DriverStatsExclude, if set, drops matching ethtool counter names from
+	// the driver-specific export.
+	DriverStatsExclude *regexp.Regexp
+	// Transceiver enables export of optical module (SFP/QSFP) diagnostics
+	// read from the module EEPROM.
+	Transceiver bool
+	// Features enables export of offload feature states.
+	Features bool
+	// FeaturesInclude, if set, limits feature export to the feature names it
+	// matches.
+	FeaturesInclude *regexp.Regexp
+	// FeaturesExclude, if set, drops matching feature names.
+	FeaturesExclude *regexp.Regexp
+	// ExpectedCoalesce lists the expected interrupt coalescing parameters
+	// that nic_config_drift compares the live settings against.
+	ExpectedCoalesce []CoalesceExpectation
+
+	// Drivers overrides the options above for the interfaces of a driver,
+	// keyed by driver name (e.g. "mlx5_core").
+	Drivers map[string]DriverOptions
+
+	// MetricsInclude, if set, limits export to the metric names it matches,
+	// e.g. "nic_rx_bytes". Exporter metrics (nic_exporter_*) are always
+	// exported.
+	MetricsInclude *regexp.Regexp
+	// MetricsExclude, if set, drops matching metric names.
+	MetricsExclude *regexp.Regexp
+	// Labels are static labels added to every interface metric; exporter
+	// metrics (nic_exporter_*) do not carry them. A static label never
+	// replaces a label of the metric itself.
+	Labels map[string]string
+}
+
+// DriverOptions override Options for the interfaces of a driver. Unset fields
+// keep the global value.
+type DriverOptions struct {
+	DriverStats        *bool
+	DriverStatsInclude *regexp.Regexp
+	DriverStatsExclude *regexp.Regexp
+	Transceiver        *bool
+	Features           *bool
+}
+
+// forDriver returns the options in effect for the interfaces of a driver.
+func (o *Options) forDriver(driver string) *Options {
+	override, ok := o.Drivers[driver]
+	if !ok {
+		return o
+	}
+
+	result := *o
+	if override.DriverStats != nil {
+		result.DriverStats = *override.DriverStats
+	}
+	if override.DriverStatsInclude != nil {
+		result.DriverStatsInclude = override.DriverStatsInclude
+	}
+	if override.DriverStatsExclude != nil {
+		result.DriverStatsExclude = override.DriverStatsExclude
+	}
+	if override.Transceiver != nil {
+		result.Transceiver = *override.Transceiver
+	}
+	if override.Features != nil {
+		result.Features = *override.Features
+	}
+	return &result
+}
+
+// isExporterMetric reports whether a fully-qualified metric name is one of
+// the exporter's own metrics, which ignore the metric filters and static
+// labels.
+func isExporterMetric(fqName string) bool {
+	return strings.HasPrefix(fqName, "nic_exporter_")
+}
+
+// exportsMetric applies the metric filters to a fully-qualified metric name.
+// Exporter metrics pass regardless of the filters.
+func (o *Options) exportsMetric(fqName string) bool {
+	if isExporterMetric(fqName) {
+		return true
+	}
+	if o.MetricsInclude != nil && !o.MetricsInclude.MatchString(fqName) {
+		return false
+	}
+	if o.MetricsExclude != nil && o.MetricsExclude.MatchString(fqName) {
+		return false
+	}
+	return true
+}
+
+// constLabels returns the static labels for a metric with the given
+// fully-qualified name and variable labels, leaving out those the metric
+// already has. Exporter metrics get none.
+func (o *Options) constLabels(fqName string, labels []string) prometheus.Labels {
+	if len(o.Labels) == 0 || isExporterMetric(fqName) {
+		return nil
+	}
+
+	result := make(prometheus.Labels, len(o.Labels))
+	for name, value := range o.Labels {
+		result[name] = value
+	}
+	for _, label := range labels {
+		delete(result, label)
+	}
+	return result
+}
+
+// SetOptions replaces the options of the collector. It waits for running
+// scrapes to finish, so that every scrape sees a single set of options.
+func (c *EthtoolCollector) SetOptions(options Options) {
+	c.reloadMu.Lock()
+	defer c.reloadMu.Unlock()
+
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+
+	// Descriptors carry the static labels and filter decisions, so they are
+	// rebuilt with the new options. A collection that timed out may still
+	// be running; its metrics are discarded, and descriptors it creates from
+	// now on are built with the new options like any other.
+	c.options.Store(&options)
+	c.metrics = make(map[string]*prometheus.Desc)
+	c.excluded = make(map[*prometheus.Desc]struct{})
+}
+
+// filterMetrics forwards the metrics sent on the returned channel to ch,
+// dropping those excluded by the metric filters. The returned function
+// closes the channel and waits for the forwarding to finish.
+func (c *EthtoolCollector) filterMetrics(ch chan<- prometheus.Metric) (chan<- prometheus.Metric, func()) {
+	opts := c.options.Load()
+	if opts.MetricsInclude == nil && opts.MetricsExclude == nil {
+		return ch, func() {}
+	}
+
+	metrics := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for m := range metrics {
+			if !c.isExcluded(m.Desc()) {
+				ch <- m
+			}
+		}
+	}()
+	return metrics, func() {
+		close(metrics)
+		<-done
+	}
+}
+
+// isExcluded reports whether the metric filters drop metrics of desc.
+func (c *EthtoolCollector) isExcluded(desc *prometheus.Desc) bool {
+	c.metricsMu.Lock()
+	defer c.metricsMu.Unlock()
+	_, excluded := c.excluded[desc]
+	return excluded
+}
@@ -0,0 +1,53 @@
+package collector
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestExportsMetric(t *testing.T) {
+	opts := Options{
+		MetricsInclude: regexp.MustCompile(`^nic_(rx|tx)_`),
+		MetricsExclude: regexp.MustCompile(`_errors$|^nic_exporter_`),
+	}
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"nic_rx_bytes", true},
+		{"nic_rx_errors", false},
+		{"nic_fec_mode_active", false},
+		// Exporter metrics ignore both filters.
+		{"nic_exporter_scrape_errors_total", true},
+		{"nic_exporter_ethtool_duration_seconds", true},
+	}
+	for _, tt := range tests {
+		if got := opts.exportsMetric(tt.name); got != tt.want {
+			t.Errorf("exportsMetric(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConstLabels(t *testing.T) {
+	opts := Options{Labels: map[string]string{"site": "ams1", "driver": "static"}}
+	tests := []struct {
+		fqName string
+		labels []string
+		want   prometheus.Labels
+	}{
+		{"nic_rx_bytes", []string{"interface"}, prometheus.Labels{"site": "ams1", "driver": "static"}},
+		// A label of the metric itself wins over the static one.
+		{"nic_rx_bytes", []string{"interface", "driver"}, prometheus.Labels{"site": "ams1"}},
+		// Exporter metrics carry no static labels.
+		{"nic_exporter_ethtool_counters", []string{"interface"}, nil},
+		{"nic_exporter_unmapped_counters", []string{"interface", "driver"}, nil},
+	}
+	for _, tt := range tests {
+		if got := opts.constLabels(tt.fqName, tt.labels); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("constLabels(%q, %v) = %v, want %v", tt.fqName, tt.labels, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package collector
+
+import (
+	"math"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// collectRxSizes exports the received packet size (RMON) buckets of an
+// interface as a cumulative histogram. Drivers do not report a sum for the
+// buckets, so the physical layer received byte count is used instead.
+func (c *EthtoolCollector) collectRxSizes(ch chan<- prometheus.Metric, sizes []drivers.SizeBucket, rxBytes uint64, labels, labelValues []string) {
+	count, buckets := cumulativeBuckets(sizes)
+	desc := c.getOrCreateMetricDesc("phy_rx_packet_size_bytes", "Size distribution of packets received at physical layer", labels)
+	ch <- prometheus.MustNewConstHistogram(desc, count, float64(rxBytes), buckets, labelValues...)
+}
+
+// cumulativeBuckets converts size buckets sorted by upper bound into the
+// total count and cumulative buckets of a histogram.
+func cumulativeBuckets(sizes []drivers.SizeBucket) (uint64, map[float64]uint64) {
+	var count uint64
+	buckets := make(map[float64]uint64, len(sizes))
+	for _, bucket := range sizes {
+		count += bucket.Count
+		// The +Inf bucket is implied by the histogram count.
+		if !math.IsInf(bucket.UpperBound, 1) {
+			buckets[bucket.UpperBound] = count
+		}
+	}
+	return count, buckets
+}
@@ -0,0 +1,49 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// packetTypeCounts holds unicast, multicast and broadcast packet counts of
+// one direction.
+type packetTypeCounts struct {
+	unicast, multicast, broadcast uint64
+}
+
+// collectPacketTypes exports received and transmitted packet counts by
+// packet type. prefix is prepended to the metric names, e.g. "phy_". Types
+// for which reports returns false for the "<direction>_<type>" counter name
+// are skipped, since the driver does not count them.
+func (c *EthtoolCollector) collectPacketTypes(ch chan<- prometheus.Metric, prefix, help string, rx, tx packetTypeCounts, reports func(counter string) bool, labels, labelValues []string) {
+	typeLabels := withLabel(labels, "type")
+
+	directions := []struct {
+		direction string
+		counts    packetTypeCounts
+	}{
+		{"rx", rx},
+		{"tx", tx},
+	}
+	for _, d := range directions {
+		desc := c.getOrCreateMetricDesc(prefix+d.direction+"_packets_by_type", help, typeLabels)
+		types := []struct {
+			name  string
+			value uint64
+		}{
+			{"unicast", d.counts.unicast},
+			{"multicast", d.counts.multicast},
+			{"broadcast", d.counts.broadcast},
+		}
+		for _, t := range types {
+			if !reports(d.direction + "_" + t.name) {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(
+				desc,
+				prometheus.CounterValue,
+				float64(t.value),
+				withLabel(labelValues, t.name)...,
+			)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestCollectPacketTypesSkipsUnreportedTypes(t *testing.T) {
+	c := newTestCollector(Options{})
+	labels := []string{"interface", "driver"}
+
+	metrics := collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.collectStats(ch, "ixgbe", &Options{}, map[string]uint64{
+			"rx_packets": 100,
+			"multicast":  7,
+			"broadcast":  3,
+		}, nil, labels, []string{"eth0", "ixgbe"})
+	})
+
+	for typ, want := range map[string]float64{"multicast": 7, "broadcast": 3} {
+		m, ok := findMetric(metrics, "nic_rx_packets_by_type", map[string]string{"type": typ})
+		if !ok || m.value != want {
+			t.Errorf("rx %s packets: got %+v (found %v), want %v", typ, m, ok, want)
+		}
+	}
+	// ixgbe has no unicast or transmit packet type counters.
+	if m, ok := findMetric(metrics, "nic_rx_packets_by_type", map[string]string{"type": "unicast"}); ok {
+		t.Errorf("unexpected rx unicast packets %+v", m)
+	}
+	if m, ok := findMetric(metrics, "nic_tx_packets_by_type", nil); ok {
+		t.Errorf("unexpected tx packets by type %+v", m)
+	}
+}
+
+func TestCollectPacketTypesReportsAllTypes(t *testing.T) {
+	c := newTestCollector(Options{})
+	labels := []string{"interface", "driver"}
+
+	metrics := collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.collectStats(ch, "ice", &Options{}, map[string]uint64{
+			"rx_unicast": 90,
+			"tx_unicast": 80,
+		}, nil, labels, []string{"eth0", "ice"})
+	})
+
+	for _, name := range []string{"nic_rx_packets_by_type", "nic_tx_packets_by_type"} {
+		for _, typ := range []string{"unicast", "multicast", "broadcast"} {
+			if _, ok := findMetric(metrics, name, map[string]string{"type": typ}); !ok {
+				t.Errorf("%s{type=%q} not exported", name, typ)
+			}
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// collectPriorityStats exports per-priority flow control (PFC) counters.
+// Pause durations and transitions are only exported for drivers that
+// provide them.
+func (c *EthtoolCollector) collectPriorityStats(ch chan<- prometheus.Metric, stats []drivers.PriorityStats, caps drivers.Capability, labels, labelValues []string) {
+	priorityLabels := withLabel(labels, "priority", "direction")
+
+	for _, pStats := range stats {
+		priority := strconv.Itoa(pStats.Priority)
+
+		counters := []struct {
+			name, help string
+			cap        drivers.Capability
+			rx, tx     float64
+		}{
+			{"phy_pfc_pause_frames_total", "Number of priority flow control pause frames",
+				drivers.CapPerPriority, float64(pStats.RxPauseFrames), float64(pStats.TxPauseFrames)},
+			{"phy_pfc_pause_duration_seconds_total", "Time spent paused by priority flow control",
+				drivers.CapPauseDuration, float64(pStats.RxPauseDuration) / 1e6, float64(pStats.TxPauseDuration) / 1e6},
+			{"phy_pfc_pause_transitions_total", "Number of priority flow control transitions from XON to XOFF",
+				drivers.CapPauseTransitions, float64(pStats.RxPauseTransitions), float64(pStats.TxPauseTransitions)},
+		}
+		for _, counter := range counters {
+			if !caps.Has(counter.cap) {
+				continue
+			}
+			desc := c.getOrCreateMetricDesc(counter.name, counter.help, priorityLabels)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, counter.rx,
+				withLabel(labelValues, priority, "rx")...)
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, counter.tx,
+				withLabel(labelValues, priority, "tx")...)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package collector
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// wrapRange is the range of a 32-bit hardware counter.
+const wrapRange = 1 << 32
+
+// counterTracker remembers the previous ethtool counter values of each
+// interface to tell counter resets (driver reload, port flap on some NICs)
+// from 32-bit hardware counters wrapping around, and can turn both into
+// monotonic 64-bit counters.
+//
+// Only the counters the driver maps to standard statistics are tracked, as
+// ethtool -S also lists gauges that legitimately go down. Drivers that cannot
+// tell which counters they map have all their counters tracked.
+type counterTracker struct {
+	monotonic bool
+
+	mu         sync.Mutex
+	interfaces map[string]*trackedCounters
+}
+
+// trackedCounters is the tracking state of one interface.
+type trackedCounters struct {
+	prev   map[string]uint64
+	offset map[string]uint64 // added to the raw value in monotonic mode
+	resets uint64
+	wraps  uint64
+}
+
+func newCounterTracker(monotonic bool) *counterTracker {
+	return &counterTracker{
+		monotonic:  monotonic,
+		interfaces: make(map[string]*trackedCounters),
+	}
+}
+
+// observe records a fresh read of the counters of an interface. It returns
+// the counters to export: stats itself, or in monotonic mode a copy with
+// resets and wraps compensated.
+func (t *counterTracker) observe(ifaceName, driverType string, stats map[string]uint64) map[string]uint64 {
+	var mapper drivers.CounterMapper
+	if d, ok := drivers.Lookup(driverType); ok {
+		mapper, _ = d.(drivers.CounterMapper)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.interfaces[ifaceName]
+	if !ok {
+		state = &trackedCounters{
+			prev:   make(map[string]uint64),
+			offset: make(map[string]uint64),
+		}
+		t.interfaces[ifaceName] = state
+	}
+
+	// A reset zeroes every counter of the interface at once, so a single
+	// decrease that cannot be a wrap makes all decreases of this read resets.
+	prev := make(map[string]uint64)
+	var decreased []string
+	reset := false
+	for name, value := range stats {
+		if mapper != nil && !mapper.Maps(name) {
+			continue
+		}
+		prev[name] = value
+
+		last, ok := state.prev[name]
+		if !ok || value >= last {
+			continue
+		}
+		decreased = append(decreased, name)
+		if !isWrap(last, value) {
+			reset = true
+		}
+	}
+
+	if reset {
+		state.resets++
+	} else {
+		state.wraps += uint64(len(decreased))
+	}
+
+	offset := make(map[string]uint64)
+	for name := range prev {
+		if o, ok := state.offset[name]; ok {
+			offset[name] = o
+		}
+	}
+	if t.monotonic {
+		for _, name := range decreased {
+			if reset {
+				// What was counted between the last read and the reset is
+				// lost; the counter resumes from its last value.
+				offset[name] += state.prev[name]
+			} else {
+				offset[name] += wrapRange
+			}
+		}
+	}
+	state.prev = prev
+	state.offset = offset
+
+	if len(offset) == 0 {
+		return stats
+	}
+	result := make(map[string]uint64, len(stats))
+	for name, value := range stats {
+		result[name] = value + offset[name]
+	}
+	return result
+}
+
+// isWrap reports whether a counter going from last down to value is a 32-bit
+// counter wrapping around rather than a reset. A wrap is assumed to advance
+// the counter by less than half of the 32-bit range between two reads.
+func isWrap(last, value uint64) bool {
+	return last < wrapRange && wrapRange-last+value <= wrapRange/2
+}
+
+// counts returns the number of resets and wraps seen on an interface.
+func (t *counterTracker) counts(ifaceName string) (resets, wraps uint64, ok bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	state, ok := t.interfaces[ifaceName]
+	if !ok {
+		return 0, 0, false
+	}
+	return state.resets, state.wraps, true
+}
+
+// prune forgets the interfaces that are not in names.
+func (t *counterTracker) prune(names []string) {
+	current := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		current[name] = struct{}{}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for name := range t.interfaces {
+		if _, ok := current[name]; !ok {
+			delete(t.interfaces, name)
+		}
+	}
+}
+
+// collectCounterResets exports the counter resets and wraps seen on an
+// interface.
+func (c *EthtoolCollector) collectCounterResets(ch chan<- prometheus.Metric, ifaceName string) {
+	resets, wraps, ok := c.counters.counts(ifaceName)
+	if !ok {
+		return
+	}
+
+	labels := []string{"interface"}
+	for _, counter := range []struct {
+		name, help string
+		value      uint64
+	}{
+		{"counter_resets_total", "Number of times the ethtool counters of an interface were reset", resets},
+		{"counter_wraps_total", "Number of times a 32-bit ethtool counter of an interface wrapped around", wraps},
+	} {
+		desc := c.getOrCreateMetricDesc(counter.name, counter.help, labels)
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(counter.value),
+			ifaceName,
+		)
+	}
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"testing"
+)
+
+func TestIsWrap(t *testing.T) {
+	tests := []struct {
+		last, value uint64
+		want        bool
+	}{
+		{last: wrapRange - 10, value: 5, want: true},
+		{last: wrapRange - 1, value: 0, want: true},
+		// Exactly half of the range since the last read still is a wrap.
+		{last: wrapRange / 2, value: 0, want: true},
+		{last: wrapRange/2 - 1, value: 0, want: false},
+		// A drop to a small value from early in the range is a reset.
+		{last: 1000, value: 10, want: false},
+		// 64-bit counters do not wrap within a scrape interval.
+		{last: wrapRange + 100, value: 5, want: false},
+	}
+	for _, tt := range tests {
+		if got := isWrap(tt.last, tt.value); got != tt.want {
+			t.Errorf("isWrap(%d, %d) = %v, want %v", tt.last, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCounterTrackerCounts(t *testing.T) {
+	tracker := newCounterTracker(false)
+	reads := []map[string]uint64{
+		{"rx_bytes": 100, "tx_bytes": wrapRange - 10},
+		// tx_bytes wraps.
+		{"rx_bytes": 200, "tx_bytes": 20},
+		// Both go down and rx_bytes cannot have wrapped: a reset.
+		{"rx_bytes": 5, "tx_bytes": 1},
+		{"rx_bytes": 50, "tx_bytes": 30},
+	}
+	for i, stats := range reads {
+		got := tracker.observe("eth0", "ice", stats)
+		for name, value := range stats {
+			if got[name] != value {
+				t.Errorf("read %d: %s = %d, want the raw value %d", i, name, got[name], value)
+			}
+		}
+	}
+
+	resets, wraps, ok := tracker.counts("eth0")
+	if !ok || resets != 1 || wraps != 1 {
+		t.Errorf("counts() = %d resets, %d wraps, %v, want 1, 1, true", resets, wraps, ok)
+	}
+}
+
+func TestCounterTrackerMonotonic(t *testing.T) {
+	tracker := newCounterTracker(true)
+	reads := []struct {
+		stats map[string]uint64
+		want  map[string]uint64
+	}{
+		{
+			stats: map[string]uint64{"rx_bytes": 100, "tx_bytes": wrapRange - 10},
+			want:  map[string]uint64{"rx_bytes": 100, "tx_bytes": wrapRange - 10},
+		},
+		{
+			// The wrap adds the 32-bit range.
+			stats: map[string]uint64{"rx_bytes": 200, "tx_bytes": 20},
+			want:  map[string]uint64{"rx_bytes": 200, "tx_bytes": wrapRange + 20},
+		},
+		{
+			// The reset resumes both counters from their last value.
+			stats: map[string]uint64{"rx_bytes": 5, "tx_bytes": 1},
+			want:  map[string]uint64{"rx_bytes": 205, "tx_bytes": wrapRange + 21},
+		},
+		{
+			stats: map[string]uint64{"rx_bytes": 50, "tx_bytes": 30},
+			want:  map[string]uint64{"rx_bytes": 250, "tx_bytes": wrapRange + 50},
+		},
+	}
+	for i, read := range reads {
+		got := tracker.observe("eth0", "ice", read.stats)
+		for name, want := range read.want {
+			if got[name] != want {
+				t.Errorf("read %d: %s = %d, want %d", i, name, got[name], want)
+			}
+		}
+	}
+}
+
+func TestCounterTrackerIgnoresUnmappedCounters(t *testing.T) {
+	tracker := newCounterTracker(true)
+
+	// ice does not map the gauge, so its decrease is neither a reset nor a
+	// wrap and it is exported as read.
+	tracker.observe("eth0", "ice", map[string]uint64{"rx_bytes": 100, "some_gauge": 50})
+	got := tracker.observe("eth0", "ice", map[string]uint64{"rx_bytes": 200, "some_gauge": 10})
+	if got["some_gauge"] != 10 {
+		t.Errorf("some_gauge = %d, want 10", got["some_gauge"])
+	}
+	if resets, wraps, _ := tracker.counts("eth0"); resets != 0 || wraps != 0 {
+		t.Errorf("counts() = %d resets, %d wraps, want none", resets, wraps)
+	}
+
+	// Without a driver mapping every counter is tracked.
+	tracker.observe("eth1", "unknown", map[string]uint64{"some_gauge": 50})
+	tracker.observe("eth1", "unknown", map[string]uint64{"some_gauge": 10})
+	if resets, _, _ := tracker.counts("eth1"); resets != 1 {
+		t.Errorf("resets of unknown driver = %d, want 1", resets)
+	}
+}
+
+func TestCounterTrackerPrune(t *testing.T) {
+	tracker := newCounterTracker(true)
+	tracker.observe("eth0", "ice", map[string]uint64{"rx_bytes": 100})
+	tracker.observe("eth1", "ice", map[string]uint64{"rx_bytes": 100})
+
+	tracker.prune([]string{"eth1"})
+	if _, _, ok := tracker.counts("eth0"); ok {
+		t.Error("eth0 still tracked after prune")
+	}
+	if _, _, ok := tracker.counts("eth1"); !ok {
+		t.Error("eth1 no longer tracked after prune")
+	}
+
+	// A returning interface starts over instead of seeing a reset.
+	got := tracker.observe("eth0", "ice", map[string]uint64{"rx_bytes": 5})
+	if got["rx_bytes"] != 5 {
+		t.Errorf("rx_bytes = %d, want 5", got["rx_bytes"])
+	}
+	if resets, _, _ := tracker.counts("eth0"); resets != 0 {
+		t.Errorf("resets = %d, want 0", resets)
+	}
+}
@@ -0,0 +1,65 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+)
+
+// collectRingsAndChannels exports ring sizes (ETHTOOL_GRINGPARAM) and channel
+// counts (ETHTOOL_GCHANNELS) of an interface.
+func (c *EthtoolCollector) collectRingsAndChannels(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	done := c.timeEthtool("ring_params")
+	ring, err := c.ioctl.RingParam(ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get ring parameters for interface %s: %v", ifaceName, err)
+	} else {
+		rings := []struct {
+			ring         string
+			current, max uint32
+		}{
+			{"rx", ring.RxPending, ring.RxMaxPending},
+			{"rx_mini", ring.RxMiniPending, ring.RxMiniMaxPending},
+			{"rx_jumbo", ring.RxJumboPending, ring.RxJumboMaxPending},
+			{"tx", ring.TxPending, ring.TxMaxPending},
+		}
+		ringLabels := withLabel(labels, "ring")
+		for _, r := range rings {
+			// Drivers report a zero maximum for ring types they lack.
+			if r.max == 0 {
+				continue
+			}
+			ringLabelValues := withLabel(labelValues, r.ring)
+			c.sendGauge(ch, "ring_size", "Configured number of ring entries",
+				ringLabels, ringLabelValues, float64(r.current))
+			c.sendGauge(ch, "ring_size_max", "Maximum number of ring entries",
+				ringLabels, ringLabelValues, float64(r.max))
+		}
+	}
+
+	done = c.timeEthtool("channels")
+	channels, err := c.ethtool.GetChannels(ifaceName)
+	done()
+	if err != nil {
+		log.Debugf("Failed to get channels for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	channelTypes := []struct {
+		channel      string
+		current, max uint32
+	}{
+		{"combined", channels.CombinedCount, channels.MaxCombined},
+		{"rx", channels.RxCount, channels.MaxRx},
+		{"tx", channels.TxCount, channels.MaxTx},
+		{"other", channels.OtherCount, channels.MaxOther},
+	}
+	channelLabels := withLabel(labels, "type")
+	for _, t := range channelTypes {
+		channelLabelValues := withLabel(labelValues, t.channel)
+		c.sendGauge(ch, "channels", "Configured number of channels",
+			channelLabels, channelLabelValues, float64(t.current))
+		c.sendGauge(ch, "channels_max", "Maximum number of channels",
+			channelLabels, channelLabelValues, float64(t.max))
+	}
+}
@@ -0,0 +1,411 @@
+package collector
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// sample is a reading of the basic counters of an interface.
+type sample struct {
+	time      time.Time
+	rxBytes   uint64
+	txBytes   uint64
+	rxPackets uint64
+	txPackets uint64
+	// speed is the negotiated link speed in bits per second, 0 if unknown.
+	speed float64
+}
+
+// sampleRing holds the most recent samples of an interface in a fixed-size
+// ring, overwriting the oldest sample once full.
+type sampleRing struct {
+	samples []sample
+	next    int
+	full    bool
+}
+
+func newSampleRing(size int) *sampleRing {
+	return &sampleRing{samples: make([]sample, size)}
+}
+
+func (r *sampleRing) add(s sample) {
+	r.samples[r.next] = s
+	r.next = (r.next + 1) % len(r.samples)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// ordered returns the samples from oldest to newest.
+func (r *sampleRing) ordered() []sample {
+	if !r.full {
+		return append([]sample(nil), r.samples[:r.next]...)
+	}
+	return append(append([]sample(nil), r.samples[r.next:]...), r.samples[:r.next]...)
+}
+
+// trafficRates are the traffic rates of an interface computed from its samples.
+type trafficRates struct {
+	RxBitsPerSecond    float64
+	TxBitsPerSecond    float64
+	RxPacketsPerSecond float64
+	TxPacketsPerSecond float64
+	// Speed is the negotiated link speed in bits per second, 0 if unknown.
+	// The utilization fields are only meaningful when it is set.
+	Speed float64
+	// RxUtilization and TxUtilization are the average fraction of the link
+	// speed used over the window.
+	RxUtilization float64
+	TxUtilization float64
+	// RxPeakUtilization and TxPeakUtilization are the highest utilization
+	// between two consecutive samples in the window.
+	RxPeakUtilization float64
+	TxPeakUtilization float64
+}
+
+// sampler reads the basic counters of a set of interfaces at a fixed interval
+// in the background and computes traffic rates over a sliding window. It
+// catches bursts shorter than the Prometheus scrape interval.
+//
+// Every interface is sampled by its own goroutine, so a driver that is slow
+// to answer only delays its own samples. Counter names are resolved once per
+// interface and the values are read into a reused buffer, keeping the cost of
+// each sample to a single statistics ioctl and a link speed query.
+type sampler struct {
+	interfaces InterfaceSource
+	interval   time.Duration
+	size       int
+	ethtool    *ethtool.Ethtool
+	ioctl      *ethtoolIoctl
+
+	mu    sync.Mutex
+	rings map[string]*sampleRing
+
+	// workers holds a stop channel per sampled interface. It is only used
+	// by run.
+	workers map[string]chan struct{}
+
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newSampler starts sampling the interfaces of a source every interval,
+// keeping the samples of the last window.
+func newSampler(interfaces InterfaceSource, interval, window time.Duration) (*sampler, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("sample interval must be positive, got %v", interval)
+	}
+	if window < interval {
+		return nil, fmt.Errorf("sample window %v is shorter than the sample interval %v", window, interval)
+	}
+
+	eth, err := ethtool.NewEthtool()
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize ethtool: %v", err)
+	}
+	ioctl, err := newEthtoolIoctl()
+	if err != nil {
+		eth.Close()
+		return nil, err
+	}
+
+	s := &sampler{
+		interfaces: interfaces,
+		interval:   interval,
+		// A window of n intervals spans n+1 samples.
+		size:    int(window/interval) + 1,
+		ethtool: eth,
+		ioctl:   ioctl,
+		rings:   make(map[string]*sampleRing),
+		workers: make(map[string]chan struct{}),
+		done:    make(chan struct{}),
+	}
+
+	s.wg.Add(1)
+	go s.run()
+
+	return s, nil
+}
+
+// Close stops sampling and releases the sampler's resources.
+func (s *sampler) Close() error {
+	close(s.done)
+	s.wg.Wait()
+	s.ethtool.Close()
+	return s.ioctl.Close()
+}
+
+// run starts and stops the interface samplers as interfaces come and go.
+func (s *sampler) run() {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	s.syncWorkers()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+			s.syncWorkers()
+		}
+	}
+}
+
+// syncWorkers starts sampling new interfaces and stops sampling and forgets
+// the interfaces that disappeared.
+func (s *sampler) syncWorkers() {
+	names := s.interfaces.Interfaces()
+	current := make(map[string]struct{}, len(names))
+
+	for _, name := range names {
+		current[name] = struct{}{}
+		if _, ok := s.workers[name]; ok {
+			continue
+		}
+		stop := make(chan struct{})
+		s.workers[name] = stop
+		s.wg.Add(1)
+		go s.sampleInterface(name, stop)
+	}
+
+	for name, stop := range s.workers {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		close(stop)
+		delete(s.workers, name)
+
+		s.mu.Lock()
+		delete(s.rings, name)
+		s.mu.Unlock()
+	}
+}
+
+// sampledInterface is the sampling state of an interface, owned by the
+// goroutine sampling it.
+type sampledInterface struct {
+	name string
+	buf  *statsBuffer
+
+	// driver, names and indexes describe the statistics of the interface.
+	// names is nil until they are resolved.
+	driver string
+	names  []string
+	// indexes are the positions in names of the counters that are read:
+	// those the driver maps to standard statistics for the sampler, the
+	// whitelisted ones for the microburst sampler.
+	indexes []int
+}
+
+// sampleInterface samples an interface every interval until stop or the
+// sampler is closed.
+func (s *sampler) sampleInterface(ifaceName string, stop <-chan struct{}) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	iface := &sampledInterface{name: ifaceName, buf: &statsBuffer{}}
+	for {
+		smp, err := s.read(iface)
+		if err != nil {
+			log.Debugf("Failed to sample interface %s: %v", ifaceName, err)
+		} else {
+			s.mu.Lock()
+			select {
+			case <-stop:
+				// The interface is gone and its ring was dropped.
+				s.mu.Unlock()
+				return
+			default:
+			}
+			ring, ok := s.rings[ifaceName]
+			if !ok {
+				ring = newSampleRing(s.size)
+				s.rings[ifaceName] = ring
+			}
+			ring.add(smp)
+			s.mu.Unlock()
+		}
+
+		select {
+		case <-s.done:
+			return
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// resolve looks up the driver and counter names of an interface.
+func (s *sampler) resolve(iface *sampledInterface) error {
+	iface.names = nil
+
+	info, err := s.ethtool.DriverInfo(iface.name)
+	if err != nil {
+		return fmt.Errorf("failed to get driver info: %v", err)
+	}
+	names, err := s.ioctl.StatNames(iface.name)
+	if err != nil {
+		return fmt.Errorf("failed to get statistic names: %v", err)
+	}
+
+	d, _ := drivers.Lookup(info.Driver)
+	mapper, ok := d.(drivers.CounterMapper)
+	iface.indexes = iface.indexes[:0]
+	for i, name := range names {
+		if !ok || mapper.Maps(name) {
+			iface.indexes = append(iface.indexes, i)
+		}
+	}
+	iface.driver = info.Driver
+	iface.names = names
+	return nil
+}
+
+// read takes a sample of an interface. The counter names are resolved again
+// when the number of statistics changes. A driver rebind re-creates the
+// network device, which fails reads or changes the number of statistics, so
+// the names are also resolved again after a failed read.
+func (s *sampler) read(iface *sampledInterface) (sample, error) {
+	if iface.names == nil {
+		if err := s.resolve(iface); err != nil {
+			return sample{}, err
+		}
+	}
+
+	values, err := s.ioctl.StatValues(iface.name, iface.buf)
+	if err != nil {
+		iface.names = nil
+		return sample{}, fmt.Errorf("failed to get ethtool stats: %v", err)
+	}
+	now := time.Now()
+
+	if len(values) != len(iface.names) {
+		if err := s.resolve(iface); err != nil {
+			return sample{}, err
+		}
+		if len(values) != len(iface.names) {
+			return sample{}, fmt.Errorf("statistics changed while resolving their names")
+		}
+	}
+
+	rawStats := make(map[string]uint64, len(iface.indexes))
+	for _, i := range iface.indexes {
+		rawStats[iface.names[i]] = values[i]
+	}
+	basic := drivers.ProcessDriverStats(iface.driver, rawStats).Basic
+
+	smp := sample{
+		time:      now,
+		rxBytes:   basic.RxBytes,
+		txBytes:   basic.TxBytes,
+		rxPackets: basic.RxPackets,
+		txPackets: basic.TxPackets,
+	}
+
+	var cmd ethtool.EthtoolCmd
+	if speed, err := s.ethtool.CmdGet(&cmd, iface.name); err == nil && speed != 0 && speed != math.MaxUint32 {
+		smp.speed = float64(speed) * 1e6
+	}
+	return smp, nil
+}
+
+// rates returns the traffic rates of an interface over the sampling window.
+// It reports false until at least two samples are available.
+func (s *sampler) rates(ifaceName string) (trafficRates, bool) {
+	s.mu.Lock()
+	ring, ok := s.rings[ifaceName]
+	var samples []sample
+	if ok {
+		samples = ring.ordered()
+	}
+	s.mu.Unlock()
+
+	if len(samples) < 2 {
+		return trafficRates{}, false
+	}
+
+	first, last := samples[0], samples[len(samples)-1]
+	elapsed := last.time.Sub(first.time).Seconds()
+	if elapsed <= 0 {
+		return trafficRates{}, false
+	}
+
+	r := trafficRates{
+		RxBitsPerSecond:    counterRate(first.rxBytes, last.rxBytes, elapsed) * 8,
+		TxBitsPerSecond:    counterRate(first.txBytes, last.txBytes, elapsed) * 8,
+		RxPacketsPerSecond: counterRate(first.rxPackets, last.rxPackets, elapsed),
+		TxPacketsPerSecond: counterRate(first.txPackets, last.txPackets, elapsed),
+		Speed:              last.speed,
+	}
+	if r.Speed == 0 {
+		return r, true
+	}
+
+	r.RxUtilization = r.RxBitsPerSecond / r.Speed
+	r.TxUtilization = r.TxBitsPerSecond / r.Speed
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		step := cur.time.Sub(prev.time).Seconds()
+		if step <= 0 || cur.speed == 0 {
+			continue
+		}
+		rx := counterRate(prev.rxBytes, cur.rxBytes, step) * 8 / cur.speed
+		tx := counterRate(prev.txBytes, cur.txBytes, step) * 8 / cur.speed
+		r.RxPeakUtilization = math.Max(r.RxPeakUtilization, rx)
+		r.TxPeakUtilization = math.Max(r.TxPeakUtilization, tx)
+	}
+	return r, true
+}
+
+// counterRate returns the per-second increase of a counter, treating a
+// decrease (driver reload, counter reset) as no traffic.
+func counterRate(from, to uint64, seconds float64) float64 {
+	if to < from {
+		return 0
+	}
+	return float64(to-from) / seconds
+}
+
+// collectRates exports the traffic rates computed by the sampler.
+func (c *EthtoolCollector) collectRates(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	r, ok := c.sampler.rates(ifaceName)
+	if !ok {
+		return
+	}
+
+	type gauge struct {
+		name, help string
+		value      float64
+	}
+	gauges := []gauge{
+		{"rx_bits_per_second", "Received bits per second over the sampling window", r.RxBitsPerSecond},
+		{"tx_bits_per_second", "Transmitted bits per second over the sampling window", r.TxBitsPerSecond},
+		{"rx_packets_per_second", "Received packets per second over the sampling window", r.RxPacketsPerSecond},
+		{"tx_packets_per_second", "Transmitted packets per second over the sampling window", r.TxPacketsPerSecond},
+	}
+	if r.Speed != 0 {
+		gauges = append(gauges, []gauge{
+			{"rx_utilization_ratio", "Average fraction of the link speed used for receiving over the sampling window", r.RxUtilization},
+			{"tx_utilization_ratio", "Average fraction of the link speed used for transmitting over the sampling window", r.TxUtilization},
+			{"rx_utilization_peak_ratio", "Highest fraction of the link speed used for receiving between two samples in the window", r.RxPeakUtilization},
+			{"tx_utilization_peak_ratio", "Highest fraction of the link speed used for transmitting between two samples in the window", r.TxPeakUtilization},
+		}...)
+	}
+
+	for _, g := range gauges {
+		c.sendGauge(ch, g.name, g.help, labels, labelValues, g.value)
+	}
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSampleRing(t *testing.T) {
+	ring := newSampleRing(3)
+	start := time.Unix(1000, 0)
+	for i := 0; i < 5; i++ {
+		ring.add(sample{time: start.Add(time.Duration(i) * time.Second), rxBytes: uint64(i)})
+
+		samples := ring.ordered()
+		want := i + 1
+		if want > 3 {
+			want = 3
+		}
+		if len(samples) != want {
+			t.Fatalf("after %d samples: got %d, want %d", i+1, len(samples), want)
+		}
+		for j, smp := range samples {
+			if wantBytes := uint64(i + 1 - want + j); smp.rxBytes != wantBytes {
+				t.Errorf("after %d samples: sample %d has rxBytes %d, want %d", i+1, j, smp.rxBytes, wantBytes)
+			}
+		}
+	}
+}
+
+func TestCounterRate(t *testing.T) {
+	tests := []struct {
+		from, to uint64
+		seconds  float64
+		want     float64
+	}{
+		{from: 100, to: 300, seconds: 2, want: 100},
+		{from: 100, to: 100, seconds: 1, want: 0},
+		// A counter reset is no traffic rather than a huge rate.
+		{from: 300, to: 100, seconds: 1, want: 0},
+	}
+	for _, tt := range tests {
+		if got := counterRate(tt.from, tt.to, tt.seconds); got != tt.want {
+			t.Errorf("counterRate(%d, %d, %v) = %v, want %v", tt.from, tt.to, tt.seconds, got, tt.want)
+		}
+	}
+}
+
+// newTestSampler returns a sampler holding the given samples of eth0.
+func newTestSampler(samples ...sample) *sampler {
+	ring := newSampleRing(len(samples) + 1)
+	for _, smp := range samples {
+		ring.add(smp)
+	}
+	return &sampler{rings: map[string]*sampleRing{"eth0": ring}}
+}
+
+func TestSamplerRates(t *testing.T) {
+	start := time.Unix(1000, 0)
+	at := func(seconds float64) time.Time {
+		return start.Add(time.Duration(seconds * float64(time.Second)))
+	}
+	const speed = 1e9 // 1 Gbit/s
+
+	tests := []struct {
+		name    string
+		samples []sample
+		want    trafficRates
+		wantOK  bool
+	}{
+		{
+			name:    "single sample",
+			samples: []sample{{time: at(0), speed: speed}},
+		},
+		{
+			name: "no elapsed time",
+			samples: []sample{
+				{time: at(0), rxBytes: 0},
+				{time: at(0), rxBytes: 100},
+			},
+		},
+		{
+			// 125 MB in 2s is 500 Mbit/s, half of the link speed on
+			// average. The first second carries 100 MB, i.e. 80% of the
+			// link.
+			name: "average and peak utilization",
+			samples: []sample{
+				{time: at(0), rxBytes: 0, txBytes: 0, rxPackets: 0, txPackets: 0, speed: speed},
+				{time: at(1), rxBytes: 100e6, txBytes: 10e6, rxPackets: 1000, txPackets: 100, speed: speed},
+				{time: at(2), rxBytes: 125e6, txBytes: 25e6, rxPackets: 3000, txPackets: 200, speed: speed},
+			},
+			want: trafficRates{
+				RxBitsPerSecond:    500e6,
+				TxBitsPerSecond:    100e6,
+				RxPacketsPerSecond: 1500,
+				TxPacketsPerSecond: 100,
+				Speed:              speed,
+				RxUtilization:      0.5,
+				TxUtilization:      0.1,
+				RxPeakUtilization:  0.8,
+				TxPeakUtilization:  0.12,
+			},
+			wantOK: true,
+		},
+		{
+			name: "unknown speed",
+			samples: []sample{
+				{time: at(0), rxBytes: 0},
+				{time: at(1), rxBytes: 1000},
+			},
+			want:   trafficRates{RxBitsPerSecond: 8000},
+			wantOK: true,
+		},
+		{
+			// A counter reset yields no traffic for the window and the
+			// interval containing it.
+			name: "counter reset",
+			samples: []sample{
+				{time: at(0), rxBytes: 200e6, speed: speed},
+				{time: at(1), rxBytes: 0, speed: speed},
+				{time: at(2), rxBytes: 125e6, speed: speed},
+			},
+			want: trafficRates{
+				Speed:             speed,
+				RxPeakUtilization: 1,
+			},
+			wantOK: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := newTestSampler(tt.samples...).rates("eth0")
+			if ok != tt.wantOK {
+				t.Fatalf("rates() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			compare := []struct {
+				name      string
+				got, want float64
+			}{
+				{"rx bits", got.RxBitsPerSecond, tt.want.RxBitsPerSecond},
+				{"tx bits", got.TxBitsPerSecond, tt.want.TxBitsPerSecond},
+				{"rx packets", got.RxPacketsPerSecond, tt.want.RxPacketsPerSecond},
+				{"tx packets", got.TxPacketsPerSecond, tt.want.TxPacketsPerSecond},
+				{"speed", got.Speed, tt.want.Speed},
+				{"rx utilization", got.RxUtilization, tt.want.RxUtilization},
+				{"tx utilization", got.TxUtilization, tt.want.TxUtilization},
+				{"rx peak utilization", got.RxPeakUtilization, tt.want.RxPeakUtilization},
+				{"tx peak utilization", got.TxPeakUtilization, tt.want.TxPeakUtilization},
+			}
+			for _, c := range compare {
+				if math.Abs(c.got-c.want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSamplerRatesUnknownInterface(t *testing.T) {
+	if _, ok := newTestSampler().rates("eth1"); ok {
+		t.Error("rates() of an unsampled interface reported ok")
+	}
+}
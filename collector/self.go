@@ -0,0 +1,105 @@
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// Collection stages counted by nic_exporter_scrape_errors_total.
+const (
+	stageLink       = "link"        // looking up the interface
+	stageDriverInfo = "driver_info" // reading driver info, or an unsupported driver
+	stageStats      = "stats"       // reading the ethtool statistics
+	stageTimeout    = "timeout"     // the interface timeout expired
+	stageInFlight   = "in_flight"   // a previous collection has not finished
+)
+
+// selfMetrics instruments the exporter itself. Unlike the interface metrics
+// they accumulate across scrapes.
+type selfMetrics struct {
+	scrapeErrors    *prometheus.CounterVec
+	ethtoolDuration *prometheus.HistogramVec
+
+	mu sync.Mutex
+	// failed holds the interfaces with scrape errors, so that their series
+	// can be deleted once they disappear.
+	failed map[string]struct{}
+}
+
+func newSelfMetrics() *selfMetrics {
+	return &selfMetrics{
+		scrapeErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "nic",
+			Subsystem: "exporter",
+			Name:      "scrape_errors_total",
+			Help:      "Failed interface collections by stage",
+		}, []string{"interface", "stage"}),
+		ethtoolDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: "nic",
+			Subsystem: "exporter",
+			Name:      "ethtool_duration_seconds",
+			Help:      "Duration of ethtool calls by call",
+			// ethtool calls take from microseconds to, on a
+			// struggling driver, seconds.
+			Buckets: prometheus.ExponentialBuckets(0.00001, 2, 18),
+		}, []string{"call"}),
+		failed: make(map[string]struct{}),
+	}
+}
+
+func (m *selfMetrics) collect(ch chan<- prometheus.Metric) {
+	m.scrapeErrors.Collect(ch)
+	m.ethtoolDuration.Collect(ch)
+}
+
+// prune deletes the scrape errors of the interfaces that are not in names.
+func (m *selfMetrics) prune(names []string) {
+	current := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		current[name] = struct{}{}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.failed {
+		if _, ok := current[name]; !ok {
+			m.scrapeErrors.DeletePartialMatch(prometheus.Labels{"interface": name})
+			delete(m.failed, name)
+		}
+	}
+}
+
+// scrapeError counts a failed collection of an interface.
+func (c *EthtoolCollector) scrapeError(ifaceName, stage string) {
+	c.self.mu.Lock()
+	defer c.self.mu.Unlock()
+	c.self.failed[ifaceName] = struct{}{}
+	c.self.scrapeErrors.WithLabelValues(ifaceName, stage).Inc()
+}
+
+// timeEthtool starts timing an ethtool call. The returned function records
+// the duration once the call returned.
+func (c *EthtoolCollector) timeEthtool(call string) func() {
+	start := time.Now()
+	return func() {
+		c.self.ethtoolDuration.WithLabelValues(call).Observe(time.Since(start).Seconds())
+	}
+}
+
+// collectCounterCounts exports how many ethtool counters were read from an
+// interface and how many of them the driver does not map to a standard
+// statistic. A growing number of unmapped counters usually means a driver
+// update renamed counters the mapping relies on.
+func (c *EthtoolCollector) collectCounterCounts(ch chan<- prometheus.Metric, driverType string, rawStats map[string]uint64, labels, labelValues []string) {
+	c.sendGauge(ch, "exporter_ethtool_counters", "Number of ethtool counters read from an interface",
+		labels, labelValues, float64(len(rawStats)))
+
+	if unmapped, ok := drivers.UnmappedCounters(driverType, rawStats); ok {
+		c.sendGauge(ch, "exporter_unmapped_counters", "Number of ethtool counters not mapped to a standard statistic",
+			labels, labelValues, float64(unmapped))
+	}
+}
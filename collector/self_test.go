@@ -0,0 +1,41 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestSelfMetricsPruneScrapeErrors(t *testing.T) {
+	c := newTestCollector(Options{})
+	c.scrapeError("eth0", stageStats)
+	c.scrapeError("eth0", stageTimeout)
+	c.scrapeError("eth1", stageStats)
+
+	c.self.prune([]string{"eth1", "eth2"})
+
+	metrics := collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.self.collect(ch)
+	})
+	if m, ok := findMetric(metrics, "nic_exporter_scrape_errors_total", map[string]string{"interface": "eth0"}); ok {
+		t.Errorf("scrape errors of removed interface eth0 still exported: %+v", m)
+	}
+	m, ok := findMetric(metrics, "nic_exporter_scrape_errors_total", map[string]string{"interface": "eth1", "stage": stageStats})
+	if !ok || m.value != 1 {
+		t.Errorf("scrape errors of eth1: got %+v (found %v), want 1", m, ok)
+	}
+
+	// An interface that comes back starts counting from zero.
+	c.scrapeError("eth0", stageStats)
+	c.self.prune([]string{"eth0"})
+	metrics = collectTestMetrics(t, func(ch chan<- prometheus.Metric) {
+		c.self.collect(ch)
+	})
+	m, ok = findMetric(metrics, "nic_exporter_scrape_errors_total", map[string]string{"interface": "eth0", "stage": stageStats})
+	if !ok || m.value != 1 {
+		t.Errorf("scrape errors of returned eth0: got %+v (found %v), want 1", m, ok)
+	}
+	if _, ok := findMetric(metrics, "nic_exporter_scrape_errors_total", map[string]string{"interface": "eth1"}); ok {
+		t.Error("scrape errors of removed interface eth1 still exported")
+	}
+}
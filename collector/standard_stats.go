@@ -0,0 +1,185 @@
+package collector
+
+import (
+	"errors"
+	"math"
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// standardStats are the driver-independent statistics of an interface: the
+// IEEE 802.3 and RFC 2819 (RMON) counters that drivers report through the
+// standardized ethtool statistics groups, and the pause frame and FEC
+// counters.
+type standardStats struct {
+	// Counters maps the metric name of every counter the driver reported,
+	// e.g. "eth_mac_fcs_errors_total", to its value.
+	Counters map[string]uint64
+	// RxHistogram and TxHistogram are the RMON packet size buckets.
+	RxHistogram []rmonBucket
+	TxHistogram []rmonBucket
+	// FEC holds the FEC counters, nil if the driver reports none. They are
+	// exported with the FEC counters mapped from the driver statistics.
+	FEC *drivers.FECStats
+}
+
+// rmonBucket counts the packets with a size between Low and High bytes.
+type rmonBucket struct {
+	Low   uint32
+	High  uint32
+	Count uint64
+}
+
+// standardCounter names a counter of a standardized statistics group.
+type standardCounter struct {
+	name string
+	help string
+}
+
+// Pause frame counters of standardStats.Counters.
+const (
+	pauseTxFrames = "pause_tx_frames_total"
+	pauseRxFrames = "pause_rx_frames_total"
+)
+
+var pauseCounterHelp = map[string]string{
+	pauseTxFrames: "Pause frames transmitted (IEEE 802.3 aPAUSEMACCtrlFramesTransmitted)",
+	pauseRxFrames: "Pause frames received (IEEE 802.3 aPAUSEMACCtrlFramesReceived)",
+}
+
+// standardStatGroups lists the standardized statistics groups. The id is the
+// group bit of ETH_SS_STATS_STD and the counters are in the order of their
+// ETHTOOL_A_STATS_* attribute types.
+var standardStatGroups = []struct {
+	id       int
+	prefix   string
+	counters []standardCounter
+}{
+	{0, "eth_phy_", []standardCounter{
+		{"symbol_errors_total", "Symbol errors during carrier (IEEE 802.3 aSymbolErrorDuringCarrier)"},
+	}},
+	{1, "eth_mac_", []standardCounter{
+		{"tx_frames_total", "Frames transmitted (IEEE 802.3 aFramesTransmittedOK)"},
+		{"single_collision_frames_total", "Frames transmitted after a single collision (IEEE 802.3 aSingleCollisionFrames)"},
+		{"multiple_collision_frames_total", "Frames transmitted after multiple collisions (IEEE 802.3 aMultipleCollisionFrames)"},
+		{"rx_frames_total", "Frames received (IEEE 802.3 aFramesReceivedOK)"},
+		{"fcs_errors_total", "Frames received with a frame check sequence error (IEEE 802.3 aFrameCheckSequenceErrors)"},
+		{"alignment_errors_total", "Frames received with an alignment error (IEEE 802.3 aAlignmentErrors)"},
+		{"tx_bytes_total", "Bytes transmitted (IEEE 802.3 aOctetsTransmittedOK)"},
+		{"deferred_frames_total", "Frames whose transmission was deferred (IEEE 802.3 aFramesWithDeferredXmissions)"},
+		{"late_collisions_total", "Late collisions (IEEE 802.3 aLateCollisions)"},
+		{"excessive_collision_frames_total", "Frames aborted due to excessive collisions (IEEE 802.3 aFramesAbortedDueToXSColls)"},
+		{"tx_internal_errors_total", "Frames lost to an internal MAC transmit error (IEEE 802.3 aFramesLostDueToIntMACXmitError)"},
+		{"carrier_sense_errors_total", "Carrier sense errors (IEEE 802.3 aCarrierSenseErrors)"},
+		{"rx_bytes_total", "Bytes received (IEEE 802.3 aOctetsReceivedOK)"},
+		{"rx_internal_errors_total", "Frames lost to an internal MAC receive error (IEEE 802.3 aFramesLostDueToIntMACRcvError)"},
+		{"tx_multicast_frames_total", "Multicast frames transmitted (IEEE 802.3 aMulticastFramesXmittedOK)"},
+		{"tx_broadcast_frames_total", "Broadcast frames transmitted (IEEE 802.3 aBroadcastFramesXmittedOK)"},
+		{"excessive_deferral_frames_total", "Frames with excessive transmission deferral (IEEE 802.3 aFramesWithExcessiveDeferral)"},
+		{"rx_multicast_frames_total", "Multicast frames received (IEEE 802.3 aMulticastFramesReceivedOK)"},
+		{"rx_broadcast_frames_total", "Broadcast frames received (IEEE 802.3 aBroadcastFramesReceivedOK)"},
+		{"in_range_length_errors_total", "Frames received with a length field mismatch (IEEE 802.3 aInRangeLengthErrors)"},
+		{"out_of_range_length_frames_total", "Frames received with an out of range length field (IEEE 802.3 aOutOfRangeLengthField)"},
+		{"frame_too_long_errors_total", "Frames received exceeding the maximum size (IEEE 802.3 aFrameTooLongErrors)"},
+	}},
+	{2, "eth_ctrl_", []standardCounter{
+		{"tx_frames_total", "MAC control frames transmitted (IEEE 802.3 aMACControlFramesTransmitted)"},
+		{"rx_frames_total", "MAC control frames received (IEEE 802.3 aMACControlFramesReceived)"},
+		{"rx_unsupported_opcodes_total", "MAC control frames received with an unsupported opcode (IEEE 802.3 aUnsupportedOpcodesReceived)"},
+	}},
+	{3, "rmon_", []standardCounter{
+		{"undersize_packets_total", "Well-formed packets received shorter than 64 bytes (RFC 2819 etherStatsUndersizePkts)"},
+		{"oversize_packets_total", "Well-formed packets received longer than the maximum size (RFC 2819 etherStatsOversizePkts)"},
+		{"fragments_total", "Packets received shorter than 64 bytes with a bad FCS (RFC 2819 etherStatsFragments)"},
+		{"jabbers_total", "Packets received longer than the maximum size with a bad FCS (RFC 2819 etherStatsJabbers)"},
+	}},
+}
+
+// standardCounterHelp returns the help text of a counter of standardStats.
+func standardCounterHelp(name string) string {
+	if help, ok := pauseCounterHelp[name]; ok {
+		return help
+	}
+	for _, group := range standardStatGroups {
+		for _, counter := range group.counters {
+			if group.prefix+counter.name == name {
+				return counter.help
+			}
+		}
+	}
+	return "Standard network interface statistic"
+}
+
+// readStandardStats reads the driver-independent statistics of an interface.
+// It returns nil if the backend cannot read them.
+func (c *EthtoolCollector) readStandardStats(ifaceName string) *standardStats {
+	if c.backend.Name() == BackendIoctl {
+		return nil
+	}
+
+	done := c.timeEthtool("standard_stats")
+	stats, err := c.backend.StandardStats(ifaceName)
+	done()
+	if errors.Is(err, errStandardStatsUnsupported) {
+		return nil
+	}
+	if err != nil {
+		log.Debugf("Failed to get standard statistics for interface %s: %v", ifaceName, err)
+		return nil
+	}
+	return stats
+}
+
+// collectStandardStats exports the driver-independent statistics of an
+// interface except the FEC counters.
+func (c *EthtoolCollector) collectStandardStats(ch chan<- prometheus.Metric, stats *standardStats, labels, labelValues []string) {
+	for name, value := range stats.Counters {
+		desc := c.getOrCreateMetricDesc(name, standardCounterHelp(name), labels)
+		ch <- prometheus.MustNewConstMetric(
+			desc,
+			prometheus.CounterValue,
+			float64(value),
+			labelValues...,
+		)
+	}
+
+	// RMON does not count bytes, so the MAC byte counters stand in for the
+	// histogram sums.
+	histograms := []struct {
+		name, help string
+		buckets    []rmonBucket
+		bytes      string
+	}{
+		{"rmon_rx_packet_size_bytes", "Size distribution of received packets (RFC 2819)", stats.RxHistogram, "eth_mac_rx_bytes_total"},
+		{"rmon_tx_packet_size_bytes", "Size distribution of transmitted packets (RFC 2819)", stats.TxHistogram, "eth_mac_tx_bytes_total"},
+	}
+	for _, h := range histograms {
+		if len(h.buckets) == 0 {
+			continue
+		}
+		count, buckets := cumulativeBuckets(rmonSizeBuckets(h.buckets))
+		desc := c.getOrCreateMetricDesc(h.name, h.help, labels)
+		ch <- prometheus.MustNewConstHistogram(desc, count, float64(stats.Counters[h.bytes]), buckets, labelValues...)
+	}
+}
+
+// rmonSizeBuckets converts RMON buckets to size buckets sorted by upper
+// bound. A bucket without upper bound becomes the +Inf bucket.
+func rmonSizeBuckets(rmon []rmonBucket) []drivers.SizeBucket {
+	sizes := make([]drivers.SizeBucket, 0, len(rmon))
+	for _, bucket := range rmon {
+		bound := float64(bucket.High)
+		if bucket.High == 0 {
+			bound = math.Inf(1)
+		}
+		sizes = append(sizes, drivers.SizeBucket{UpperBound: bound, Count: bucket.Count})
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		return sizes[i].UpperBound < sizes[j].UpperBound
+	})
+	return sizes
+}
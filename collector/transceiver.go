@@ -0,0 +1,84 @@
+package collector
+
+import (
+	"strconv"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector/transceiver"
+)
+
+// collectTransceiver exports identity, digital optical monitoring readings
+// and thresholds of the pluggable module of an interface, if any.
+func (c *EthtoolCollector) collectTransceiver(ch chan<- prometheus.Metric, ifaceName string, labels, labelValues []string) {
+	done := c.timeEthtool("module_eeprom")
+	eeprom, err := c.ethtool.ModuleEeprom(ifaceName)
+	done()
+	if err != nil {
+		// Interfaces without a pluggable module fail here.
+		log.Debugf("Failed to read module EEPROM for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	module, err := transceiver.Decode(eeprom)
+	if err != nil {
+		log.Debugf("Failed to decode module EEPROM for interface %s: %v", ifaceName, err)
+		return
+	}
+
+	c.sendGauge(ch, "transceiver_info", "Transceiver module information (constant 1)",
+		withLabel(labels, "standard", "identifier", "vendor", "part_number", "revision", "serial"),
+		withLabel(labelValues, string(module.Standard), module.Identifier, module.Vendor, module.PartNumber, module.Revision, module.SerialNumber),
+		1)
+
+	diag := module.Diagnostics
+	if diag == nil {
+		return
+	}
+
+	c.sendGauge(ch, "transceiver_temperature_celsius", "Transceiver module temperature",
+		labels, labelValues, diag.Temperature)
+	c.sendGauge(ch, "transceiver_supply_voltage_volts", "Transceiver module supply voltage",
+		labels, labelValues, diag.Voltage)
+
+	laneLabels := withLabel(labels, "lane")
+	for _, lane := range diag.Lanes {
+		laneLabelValues := withLabel(labelValues, strconv.Itoa(lane.Index))
+		c.sendGauge(ch, "transceiver_tx_bias_current_amperes", "Transceiver laser bias current per lane",
+			laneLabels, laneLabelValues, lane.TxBias)
+		c.sendGauge(ch, "transceiver_rx_power_watts", "Transceiver received optical power per lane",
+			laneLabels, laneLabelValues, lane.RxPower)
+		if diag.TxPowerSupported {
+			c.sendGauge(ch, "transceiver_tx_power_watts", "Transceiver transmitted optical power per lane",
+				laneLabels, laneLabelValues, lane.TxPower)
+		}
+	}
+
+	if diag.Thresholds == nil {
+		return
+	}
+
+	thresholds := []struct {
+		name, help string
+		threshold  transceiver.Threshold
+	}{
+		{"transceiver_temperature_threshold_celsius", "Transceiver temperature alarm and warning thresholds", diag.Thresholds.Temperature},
+		{"transceiver_supply_voltage_threshold_volts", "Transceiver supply voltage alarm and warning thresholds", diag.Thresholds.Voltage},
+		{"transceiver_tx_bias_current_threshold_amperes", "Transceiver laser bias current alarm and warning thresholds", diag.Thresholds.TxBias},
+		{"transceiver_tx_power_threshold_watts", "Transceiver transmitted optical power alarm and warning thresholds", diag.Thresholds.TxPower},
+		{"transceiver_rx_power_threshold_watts", "Transceiver received optical power alarm and warning thresholds", diag.Thresholds.RxPower},
+	}
+	levelLabels := withLabel(labels, "level")
+	for _, t := range thresholds {
+		levels := map[string]float64{
+			"high_alarm":   t.threshold.HighAlarm,
+			"low_alarm":    t.threshold.LowAlarm,
+			"high_warning": t.threshold.HighWarning,
+			"low_warning":  t.threshold.LowWarning,
+		}
+		for level, value := range levels {
+			c.sendGauge(ch, t.name, t.help, levelLabels, withLabel(labelValues, level), value)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package transceiver
+
+// CMIS layout. The legacy ethtool module EEPROM interface only exposes the
+// lower page (0-127) and upper page 00h (128-255). Lane monitors (page 11h)
+// and thresholds (page 02h) are not part of such dumps, so only module-level
+// temperature and supply voltage are decoded.
+const (
+	cmisLen = 256
+
+	cmisTemperature = 14
+	cmisVoltage     = 16
+	cmisVendor      = 129
+	cmisPartNumber  = 148
+	cmisRevision    = 164
+	cmisSerial      = 166
+)
+
+func decodeCMIS(eeprom []byte) (*Module, error) {
+	if len(eeprom) < cmisLen {
+		return nil, ErrTruncated
+	}
+
+	return &Module{
+		Standard:     CMIS,
+		Identifier:   identifierName(eeprom[0]),
+		Vendor:       asciiField(eeprom, cmisVendor, 16),
+		PartNumber:   asciiField(eeprom, cmisPartNumber, 16),
+		Revision:     asciiField(eeprom, cmisRevision, 2),
+		SerialNumber: asciiField(eeprom, cmisSerial, 16),
+		Diagnostics: &Diagnostics{
+			Temperature: temperatureAt(eeprom, cmisTemperature),
+			Voltage:     voltageAt(eeprom, cmisVoltage),
+		},
+	}, nil
+}
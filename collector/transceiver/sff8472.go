@@ -0,0 +1,155 @@
+package transceiver
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// SFF-8472 layout. The dump holds page A0h at 0-255 followed by the
+// diagnostics page A2h at 256-511.
+const (
+	sff8472A0Len = 256
+	sff8472A2    = 256
+	sff8472Len   = 512
+
+	sff8472Vendor       = 20
+	sff8472PartNumber   = 40
+	sff8472Revision     = 56
+	sff8472SerialNumber = 68
+	sff8472DiagType     = 92
+	sff8472DiagImpl     = 1 << 6
+	sff8472DiagExtCal   = 1 << 4
+
+	// Offsets within page A2h.
+	sff8472TempThresholds    = 0
+	sff8472VoltThresholds    = 8
+	sff8472BiasThresholds    = 16
+	sff8472TxPowerThresholds = 24
+	sff8472RxPowerThresholds = 32
+	sff8472RxPowerCal        = 56 // Rx_PWR(4) .. Rx_PWR(0), IEEE 754 floats
+	sff8472TxBiasCal         = 76 // slope, offset
+	sff8472TxPowerCal        = 80
+	sff8472TempCal           = 84
+	sff8472VoltCal           = 88
+	sff8472Temperature       = 96
+	sff8472Voltage           = 98
+	sff8472TxBias            = 100
+	sff8472TxPower           = 102
+	sff8472RxPower           = 104
+)
+
+func decodeSFF8472(eeprom []byte) (*Module, error) {
+	if len(eeprom) < sff8472A0Len {
+		return nil, ErrTruncated
+	}
+
+	module := &Module{
+		Standard:     SFF8472,
+		Identifier:   identifierName(eeprom[0]),
+		Vendor:       asciiField(eeprom, sff8472Vendor, 16),
+		PartNumber:   asciiField(eeprom, sff8472PartNumber, 16),
+		Revision:     asciiField(eeprom, sff8472Revision, 4),
+		SerialNumber: asciiField(eeprom, sff8472SerialNumber, 16),
+	}
+
+	diagType := eeprom[sff8472DiagType]
+	if diagType&sff8472DiagImpl == 0 || len(eeprom) < sff8472Len {
+		return module, nil
+	}
+
+	a2 := eeprom[sff8472A2:sff8472Len]
+	cal := newSFF8472Calibration(a2, diagType&sff8472DiagExtCal != 0)
+
+	module.Diagnostics = &Diagnostics{
+		Temperature: cal.temperature(a2, sff8472Temperature),
+		Voltage:     cal.voltage(a2, sff8472Voltage),
+		Lanes: []Lane{{
+			Index:   1,
+			TxBias:  cal.bias(a2, sff8472TxBias),
+			TxPower: cal.txPower(a2, sff8472TxPower),
+			RxPower: cal.rxPower(a2, sff8472RxPower),
+		}},
+		TxPowerSupported: true,
+		Thresholds: &Thresholds{
+			Temperature: threshold(a2, sff8472TempThresholds, cal.temperature),
+			Voltage:     threshold(a2, sff8472VoltThresholds, cal.voltage),
+			TxBias:      threshold(a2, sff8472BiasThresholds, cal.bias),
+			TxPower:     threshold(a2, sff8472TxPowerThresholds, cal.txPower),
+			RxPower:     threshold(a2, sff8472RxPowerThresholds, cal.rxPower),
+		},
+	}
+
+	return module, nil
+}
+
+// sff8472Calibration converts raw A2h values to base units. Internally
+// calibrated modules report calibrated values directly; externally
+// calibrated modules provide slope/offset pairs and an Rx power polynomial.
+type sff8472Calibration struct {
+	external bool
+
+	tempSlope, tempOffset       float64
+	voltSlope, voltOffset       float64
+	biasSlope, biasOffset       float64
+	txPowerSlope, txPowerOffset float64
+	rxPowerCoefficients         [5]float64 // Rx_PWR(0) .. Rx_PWR(4)
+}
+
+func newSFF8472Calibration(a2 []byte, external bool) sff8472Calibration {
+	cal := sff8472Calibration{external: external}
+	if !external {
+		return cal
+	}
+
+	slopeOffset := func(offset int) (float64, float64) {
+		// Slopes are unsigned fixed-point 8.8, offsets signed integers.
+		return float64(u16(a2, offset)) / 256, float64(s16(a2, offset+2))
+	}
+	cal.biasSlope, cal.biasOffset = slopeOffset(sff8472TxBiasCal)
+	cal.txPowerSlope, cal.txPowerOffset = slopeOffset(sff8472TxPowerCal)
+	cal.tempSlope, cal.tempOffset = slopeOffset(sff8472TempCal)
+	cal.voltSlope, cal.voltOffset = slopeOffset(sff8472VoltCal)
+	for i := range cal.rxPowerCoefficients {
+		// Stored highest order first.
+		offset := sff8472RxPowerCal + (len(cal.rxPowerCoefficients)-1-i)*4
+		cal.rxPowerCoefficients[i] = float64(math.Float32frombits(binary.BigEndian.Uint32(a2[offset:])))
+	}
+	return cal
+}
+
+func (cal sff8472Calibration) linear(raw, slope, offset float64) float64 {
+	if !cal.external {
+		return raw
+	}
+	return slope*raw + offset
+}
+
+func (cal sff8472Calibration) temperature(b []byte, offset int) float64 {
+	return cal.linear(float64(s16(b, offset)), cal.tempSlope, cal.tempOffset) * temperatureUnit
+}
+
+func (cal sff8472Calibration) voltage(b []byte, offset int) float64 {
+	return cal.linear(float64(u16(b, offset)), cal.voltSlope, cal.voltOffset) * voltageUnit
+}
+
+func (cal sff8472Calibration) bias(b []byte, offset int) float64 {
+	return cal.linear(float64(u16(b, offset)), cal.biasSlope, cal.biasOffset) * biasUnit
+}
+
+func (cal sff8472Calibration) txPower(b []byte, offset int) float64 {
+	return cal.linear(float64(u16(b, offset)), cal.txPowerSlope, cal.txPowerOffset) * powerUnit
+}
+
+func (cal sff8472Calibration) rxPower(b []byte, offset int) float64 {
+	raw := float64(u16(b, offset))
+	if !cal.external {
+		return raw * powerUnit
+	}
+
+	var value, power float64 = 0, 1
+	for _, coefficient := range cal.rxPowerCoefficients {
+		value += coefficient * power
+		power *= raw
+	}
+	return value * powerUnit
+}
@@ -0,0 +1,78 @@
+package transceiver
+
+// SFF-8636 layout. The dump holds the lower page at 0-127 and upper page 00h
+// at 128-255. Dumps of paged modules may continue with upper pages 01h-03h,
+// 128 bytes each, so page 03h (thresholds) starts at 512.
+const (
+	sff8636Len      = 256
+	sff8636PagedLen = 640
+
+	sff8636Status      = 2
+	sff8636FlatMem     = 1 << 2
+	sff8636Temperature = 22
+	sff8636Voltage     = 26
+	sff8636RxPower     = 34
+	sff8636TxBias      = 42
+	sff8636TxPower     = 50
+	sff8636Vendor      = 148
+	sff8636PartNumber  = 168
+	sff8636Revision    = 184
+	sff8636Serial      = 196
+	sff8636DiagType    = 220
+	sff8636DiagTxPower = 1 << 2
+	sff8636Lanes       = 4
+	sff8636Page03      = 512 - 128 // dump offset of page 03h byte 0
+	sff8636TempThresh  = sff8636Page03 + 128
+	sff8636VoltThresh  = sff8636Page03 + 144
+	sff8636RxPowThresh = sff8636Page03 + 176
+	sff8636BiasThresh  = sff8636Page03 + 184
+	sff8636TxPowThresh = sff8636Page03 + 192
+	sff8636LaneStride  = 2
+)
+
+func decodeSFF8636(eeprom []byte) (*Module, error) {
+	if len(eeprom) < sff8636Len {
+		return nil, ErrTruncated
+	}
+
+	module := &Module{
+		Standard:     SFF8636,
+		Identifier:   identifierName(eeprom[0]),
+		Vendor:       asciiField(eeprom, sff8636Vendor, 16),
+		PartNumber:   asciiField(eeprom, sff8636PartNumber, 16),
+		Revision:     asciiField(eeprom, sff8636Revision, 2),
+		SerialNumber: asciiField(eeprom, sff8636Serial, 16),
+	}
+
+	diag := &Diagnostics{
+		Temperature:      temperatureAt(eeprom, sff8636Temperature),
+		Voltage:          voltageAt(eeprom, sff8636Voltage),
+		TxPowerSupported: eeprom[sff8636DiagType]&sff8636DiagTxPower != 0,
+	}
+	for lane := 0; lane < sff8636Lanes; lane++ {
+		offset := lane * sff8636LaneStride
+		l := Lane{
+			Index:   lane + 1,
+			TxBias:  biasAt(eeprom, sff8636TxBias+offset),
+			RxPower: powerAt(eeprom, sff8636RxPower+offset),
+		}
+		if diag.TxPowerSupported {
+			l.TxPower = powerAt(eeprom, sff8636TxPower+offset)
+		}
+		diag.Lanes = append(diag.Lanes, l)
+	}
+
+	flatMem := eeprom[sff8636Status]&sff8636FlatMem != 0
+	if !flatMem && len(eeprom) >= sff8636PagedLen {
+		diag.Thresholds = &Thresholds{
+			Temperature: threshold(eeprom, sff8636TempThresh, temperatureAt),
+			Voltage:     threshold(eeprom, sff8636VoltThresh, voltageAt),
+			TxBias:      threshold(eeprom, sff8636BiasThresh, biasAt),
+			TxPower:     threshold(eeprom, sff8636TxPowThresh, powerAt),
+			RxPower:     threshold(eeprom, sff8636RxPowThresh, powerAt),
+		}
+	}
+
+	module.Diagnostics = diag
+	return module, nil
+}
@@ -0,0 +1,189 @@
+// Package transceiver decodes the management memory of pluggable optical
+// transceivers (SFP, QSFP, QSFP-DD, ...) as read through ethtool module
+// EEPROM dumps. It understands the SFF-8472, SFF-8636 and CMIS layouts and
+// extracts module identity, digital optical monitoring (DOM) readings and
+// alarm/warning thresholds.
+//
+// Decoding is a pure function of the EEPROM bytes, so captured dumps (e.g.
+// from `ethtool -m <iface> raw on`) can be decoded offline.
+package transceiver
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Standard is the management interface specification a module implements.
+type Standard string
+
+// Supported management interface specifications.
+const (
+	SFF8472 Standard = "SFF-8472"
+	SFF8636 Standard = "SFF-8636"
+	CMIS    Standard = "CMIS"
+)
+
+// Errors returned by Decode.
+var (
+	ErrTruncated   = errors.New("transceiver: EEPROM dump is truncated")
+	ErrUnsupported = errors.New("transceiver: unsupported module identifier")
+)
+
+// SFF-8024 identifier values (byte 0).
+const (
+	idSFP         = 0x03
+	idQSFP        = 0x0c
+	idQSFPPlus    = 0x0d
+	idQSFP28      = 0x11
+	idQSFPDD      = 0x18
+	idOSFP        = 0x19
+	idQSFPCMIS    = 0x1e
+	idSFPDDCMIS   = 0x1f
+	idSFPPlusCMIS = 0x20
+)
+
+var identifierNames = map[byte]string{
+	idSFP:         "SFP",
+	idQSFP:        "QSFP",
+	idQSFPPlus:    "QSFP+",
+	idQSFP28:      "QSFP28",
+	idQSFPDD:      "QSFP-DD",
+	idOSFP:        "OSFP",
+	idQSFPCMIS:    "QSFP+ (CMIS)",
+	idSFPDDCMIS:   "SFP-DD (CMIS)",
+	idSFPPlusCMIS: "SFP+ (CMIS)",
+}
+
+// Module is the decoded content of a transceiver EEPROM.
+type Module struct {
+	Standard     Standard
+	Identifier   string
+	Vendor       string
+	PartNumber   string
+	Revision     string
+	SerialNumber string
+
+	// Diagnostics is nil if the module does not implement digital
+	// optical monitoring or the dump does not contain it.
+	Diagnostics *Diagnostics
+}
+
+// Diagnostics holds digital optical monitoring readings in base units.
+type Diagnostics struct {
+	// Temperature is the internal module temperature in degrees Celsius.
+	Temperature float64
+	// Voltage is the supply voltage in volts.
+	Voltage float64
+	// Lanes holds per-lane readings, numbered from 1. It is empty if the
+	// dump does not include lane monitors.
+	Lanes []Lane
+	// TxPowerSupported reports whether Lane.TxPower is measured.
+	TxPowerSupported bool
+
+	// Thresholds is nil if the dump does not contain the threshold page.
+	Thresholds *Thresholds
+}
+
+// Lane holds the monitors of one optical lane.
+type Lane struct {
+	Index int
+	// TxBias is the laser bias current in amperes.
+	TxBias float64
+	// TxPower is the transmitted optical power in watts.
+	TxPower float64
+	// RxPower is the received optical power in watts.
+	RxPower float64
+}
+
+// Threshold holds the alarm and warning limits of a monitor.
+type Threshold struct {
+	HighAlarm   float64
+	LowAlarm    float64
+	HighWarning float64
+	LowWarning  float64
+}
+
+// Thresholds holds the limits of all monitors, in the same units as the
+// readings in Diagnostics.
+type Thresholds struct {
+	Temperature Threshold
+	Voltage     Threshold
+	TxBias      Threshold
+	TxPower     Threshold
+	RxPower     Threshold
+}
+
+// Decode decodes a module EEPROM dump. The layout is selected from the
+// SFF-8024 identifier in byte 0.
+func Decode(eeprom []byte) (*Module, error) {
+	if len(eeprom) == 0 {
+		return nil, ErrTruncated
+	}
+
+	switch eeprom[0] {
+	case idSFP:
+		return decodeSFF8472(eeprom)
+	case idQSFP, idQSFPPlus, idQSFP28:
+		return decodeSFF8636(eeprom)
+	case idQSFPDD, idOSFP, idQSFPCMIS, idSFPDDCMIS, idSFPPlusCMIS:
+		return decodeCMIS(eeprom)
+	}
+	return nil, fmt.Errorf("%w 0x%02x", ErrUnsupported, eeprom[0])
+}
+
+// Units of the raw monitor values shared by all supported specifications.
+const (
+	temperatureUnit = 1.0 / 256 // degrees Celsius, signed
+	voltageUnit     = 100e-6    // volts
+	biasUnit        = 2e-6      // amperes
+	powerUnit       = 0.1e-6    // watts
+)
+
+func identifierName(id byte) string {
+	if name, ok := identifierNames[id]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%02x", id)
+}
+
+func u16(b []byte, offset int) uint16 {
+	return binary.BigEndian.Uint16(b[offset:])
+}
+
+func s16(b []byte, offset int) int16 {
+	return int16(binary.BigEndian.Uint16(b[offset:]))
+}
+
+// asciiField decodes a space padded ASCII field.
+func asciiField(b []byte, offset, length int) string {
+	return strings.TrimSpace(strings.TrimRight(string(b[offset:offset+length]), "\x00"))
+}
+
+// threshold decodes four consecutive 16-bit limits in the order high alarm,
+// low alarm, high warning, low warning, which all specifications share.
+func threshold(b []byte, offset int, decode func(b []byte, offset int) float64) Threshold {
+	return Threshold{
+		HighAlarm:   decode(b, offset),
+		LowAlarm:    decode(b, offset+2),
+		HighWarning: decode(b, offset+4),
+		LowWarning:  decode(b, offset+6),
+	}
+}
+
+func temperatureAt(b []byte, offset int) float64 {
+	return float64(s16(b, offset)) * temperatureUnit
+}
+
+func voltageAt(b []byte, offset int) float64 {
+	return float64(u16(b, offset)) * voltageUnit
+}
+
+func biasAt(b []byte, offset int) float64 {
+	return float64(u16(b, offset)) * biasUnit
+}
+
+func powerAt(b []byte, offset int) float64 {
+	return float64(u16(b, offset)) * powerUnit
+}
@@ -0,0 +1,273 @@
+package transceiver
+
+import (
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// The dumps in testdata are in the format of `ethtool -m <iface> raw on`.
+
+func readDump(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecode(t *testing.T) {
+	sfpThresholds := &Thresholds{
+		Temperature: Threshold{HighAlarm: 75, LowAlarm: -5, HighWarning: 70, LowWarning: 0},
+		Voltage:     Threshold{HighAlarm: 3.63, LowAlarm: 2.97, HighWarning: 3.465, LowWarning: 3.135},
+		TxBias:      Threshold{HighAlarm: 13.2e-3, LowAlarm: 4e-3, HighWarning: 12e-3, LowWarning: 5e-3},
+		TxPower:     Threshold{HighAlarm: 1e-3, LowAlarm: 0.1e-3, HighWarning: 0.7943e-3, LowWarning: 0.1259e-3},
+	}
+	sfpIdentity := Module{
+		Standard:     SFF8472,
+		Identifier:   "SFP",
+		Vendor:       "FINISAR CORP.",
+		PartNumber:   "FTLX8574D3BCL",
+		Revision:     "A",
+		SerialNumber: "ALJ0Q1K",
+	}
+	qsfpIdentity := Module{
+		Standard:     SFF8636,
+		Identifier:   "QSFP28",
+		Vendor:       "Mellanox",
+		PartNumber:   "MMA1B00-C100D",
+		Revision:     "B2",
+		SerialNumber: "MT2031FT01234",
+	}
+
+	tests := []struct {
+		name string
+		dump string
+		// length truncates the dump if set.
+		length int
+		want   func() *Module
+	}{
+		{
+			name: "SFF-8472 internal calibration",
+			dump: "sfp-internal-cal.bin",
+			want: func() *Module {
+				m := sfpIdentity
+				thresholds := *sfpThresholds
+				thresholds.RxPower = Threshold{HighAlarm: 1e-3, LowAlarm: 0.01e-3, HighWarning: 0.7943e-3, LowWarning: 0.0158e-3}
+				m.Diagnostics = &Diagnostics{
+					Temperature:      35.5,
+					Voltage:          3.3,
+					Lanes:            []Lane{{Index: 1, TxBias: 6.5e-3, TxPower: 0.5e-3, RxPower: 0.4e-3}},
+					TxPowerSupported: true,
+					Thresholds:       &thresholds,
+				}
+				return &m
+			},
+		},
+		{
+			// Raw readings are slope/offset corrected and the Rx power is
+			// 1.5*raw + 10 in units of 0.1 uW.
+			name: "SFF-8472 external calibration",
+			dump: "sfp-external-cal.bin",
+			want: func() *Module {
+				m := sfpIdentity
+				thresholds := *sfpThresholds
+				thresholds.RxPower = Threshold{HighAlarm: 1e-3, LowAlarm: 0.01e-3, HighWarning: 0.79435e-3, LowWarning: 0.01585e-3}
+				m.Diagnostics = &Diagnostics{
+					Temperature:      36,
+					Voltage:          3.3,
+					Lanes:            []Lane{{Index: 1, TxBias: 6.2e-3, TxPower: 0.5e-3, RxPower: 0.301e-3}},
+					TxPowerSupported: true,
+					Thresholds:       &thresholds,
+				}
+				return &m
+			},
+		},
+		{
+			name:   "SFF-8472 without diagnostics page",
+			dump:   "sfp-internal-cal.bin",
+			length: sff8472A0Len,
+			want: func() *Module {
+				m := sfpIdentity
+				return &m
+			},
+		},
+		{
+			name: "SFF-8636 flat memory",
+			dump: "qsfp28-flat.bin",
+			want: func() *Module {
+				m := qsfpIdentity
+				m.Diagnostics = &Diagnostics{
+					Temperature: 40.25,
+					Voltage:     3.29,
+					Lanes: []Lane{
+						{Index: 1, TxBias: 6.0e-3, TxPower: 0.70e-3, RxPower: 0.80e-3},
+						{Index: 2, TxBias: 6.2e-3, TxPower: 0.71e-3, RxPower: 0.81e-3},
+						{Index: 3, TxBias: 6.4e-3, TxPower: 0.72e-3, RxPower: 0.82e-3},
+						{Index: 4, TxBias: 6.6e-3, TxPower: 0.73e-3, RxPower: 0.83e-3},
+					},
+					TxPowerSupported: true,
+				}
+				return &m
+			},
+		},
+		{
+			// The module does not measure Tx power, so the Tx power bytes
+			// are ignored.
+			name: "SFF-8636 paged with page 03h thresholds",
+			dump: "qsfp28-paged.bin",
+			want: func() *Module {
+				m := qsfpIdentity
+				m.Diagnostics = &Diagnostics{
+					Temperature: 40.25,
+					Voltage:     3.29,
+					Lanes: []Lane{
+						{Index: 1, TxBias: 6.0e-3, RxPower: 0.80e-3},
+						{Index: 2, TxBias: 6.2e-3, RxPower: 0.81e-3},
+						{Index: 3, TxBias: 6.4e-3, RxPower: 0.82e-3},
+						{Index: 4, TxBias: 6.6e-3, RxPower: 0.83e-3},
+					},
+					Thresholds: &Thresholds{
+						Temperature: Threshold{HighAlarm: 80, LowAlarm: -10, HighWarning: 75, LowWarning: -5},
+						Voltage:     Threshold{HighAlarm: 3.6, LowAlarm: 3.0, HighWarning: 3.5, LowWarning: 3.1},
+						TxBias:      Threshold{HighAlarm: 15e-3, LowAlarm: 2e-3, HighWarning: 14e-3, LowWarning: 3e-3},
+						TxPower:     Threshold{HighAlarm: 3.4674e-3, LowAlarm: 0.1e-3, HighWarning: 2.7542e-3, LowWarning: 0.1585e-3},
+						RxPower:     Threshold{HighAlarm: 3.4674e-3, LowAlarm: 0.1e-3, HighWarning: 2.7542e-3, LowWarning: 0.1585e-3},
+					},
+				}
+				return &m
+			},
+		},
+		{
+			name: "CMIS lower page",
+			dump: "qsfp-dd-cmis.bin",
+			want: func() *Module {
+				return &Module{
+					Standard:     CMIS,
+					Identifier:   "QSFP-DD",
+					Vendor:       "INNOLIGHT",
+					PartNumber:   "T-DP4CNT-N00",
+					Revision:     "1A",
+					SerialNumber: "INKAT1234567",
+					Diagnostics: &Diagnostics{
+						Temperature: 45,
+						Voltage:     3.31,
+					},
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dump := readDump(t, tt.dump)
+			if tt.length > 0 {
+				dump = dump[:tt.length]
+			}
+
+			got, err := Decode(dump)
+			if err != nil {
+				t.Fatalf("Decode() = %v", err)
+			}
+			compareModules(t, got, tt.want())
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	unknown := readDump(t, "sfp-internal-cal.bin")
+	unknown[0] = 0x42
+
+	tests := []struct {
+		name    string
+		dump    []byte
+		wantErr error
+		wantMsg string
+	}{
+		{name: "empty", dump: nil, wantErr: ErrTruncated},
+		{name: "SFF-8472 truncated", dump: readDump(t, "sfp-internal-cal.bin")[:128], wantErr: ErrTruncated},
+		{name: "SFF-8636 truncated", dump: readDump(t, "qsfp28-flat.bin")[:128], wantErr: ErrTruncated},
+		{name: "CMIS truncated", dump: readDump(t, "qsfp-dd-cmis.bin")[:200], wantErr: ErrTruncated},
+		{
+			name:    "unknown identifier",
+			dump:    unknown,
+			wantErr: ErrUnsupported,
+			wantMsg: "transceiver: unsupported module identifier 0x42",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			module, err := Decode(tt.dump)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() = %v, %v, want error %v", module, err, tt.wantErr)
+			}
+			if tt.wantMsg != "" && err.Error() != tt.wantMsg {
+				t.Errorf("error = %q, want %q", err, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func compareModules(t *testing.T, got, want *Module) {
+	t.Helper()
+	gotIdentity, wantIdentity := *got, *want
+	gotIdentity.Diagnostics, wantIdentity.Diagnostics = nil, nil
+	if gotIdentity != wantIdentity {
+		t.Errorf("module = %+v, want %+v", gotIdentity, wantIdentity)
+	}
+
+	if (got.Diagnostics == nil) != (want.Diagnostics == nil) {
+		t.Fatalf("diagnostics = %+v, want %+v", got.Diagnostics, want.Diagnostics)
+	}
+	if want.Diagnostics == nil {
+		return
+	}
+	g, w := got.Diagnostics, want.Diagnostics
+	compareValue(t, "temperature", g.Temperature, w.Temperature)
+	compareValue(t, "voltage", g.Voltage, w.Voltage)
+	if g.TxPowerSupported != w.TxPowerSupported {
+		t.Errorf("tx power supported = %v, want %v", g.TxPowerSupported, w.TxPowerSupported)
+	}
+	if len(g.Lanes) != len(w.Lanes) {
+		t.Fatalf("lanes = %+v, want %+v", g.Lanes, w.Lanes)
+	}
+	for i := range w.Lanes {
+		if g.Lanes[i].Index != w.Lanes[i].Index {
+			t.Errorf("lane %d index = %d, want %d", i, g.Lanes[i].Index, w.Lanes[i].Index)
+		}
+		compareValue(t, "tx bias", g.Lanes[i].TxBias, w.Lanes[i].TxBias)
+		compareValue(t, "tx power", g.Lanes[i].TxPower, w.Lanes[i].TxPower)
+		compareValue(t, "rx power", g.Lanes[i].RxPower, w.Lanes[i].RxPower)
+	}
+
+	if (g.Thresholds == nil) != (w.Thresholds == nil) {
+		t.Fatalf("thresholds = %+v, want %+v", g.Thresholds, w.Thresholds)
+	}
+	if w.Thresholds == nil {
+		return
+	}
+	compareThreshold(t, "temperature", g.Thresholds.Temperature, w.Thresholds.Temperature)
+	compareThreshold(t, "voltage", g.Thresholds.Voltage, w.Thresholds.Voltage)
+	compareThreshold(t, "tx bias", g.Thresholds.TxBias, w.Thresholds.TxBias)
+	compareThreshold(t, "tx power", g.Thresholds.TxPower, w.Thresholds.TxPower)
+	compareThreshold(t, "rx power", g.Thresholds.RxPower, w.Thresholds.RxPower)
+}
+
+func compareThreshold(t *testing.T, name string, got, want Threshold) {
+	t.Helper()
+	compareValue(t, name+" high alarm", got.HighAlarm, want.HighAlarm)
+	compareValue(t, name+" low alarm", got.LowAlarm, want.LowAlarm)
+	compareValue(t, name+" high warning", got.HighWarning, want.HighWarning)
+	compareValue(t, name+" low warning", got.LowWarning, want.LowWarning)
+}
+
+func compareValue(t *testing.T, name string, got, want float64) {
+	t.Helper()
+	if math.Abs(got-want) > 1e-9*math.Max(1, math.Abs(want)) {
+		t.Errorf("%s = %g, want %g", name, got, want)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/safchain/ethtool"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v3"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector"
+	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
+)
+
+// labelNameRE matches valid Prometheus label names.
+var labelNameRE = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// fileConfig is the schema of the file passed with -config.file.
+type fileConfig struct {
+	// Interfaces, if set, replaces the -interfaces, -interfaces.include and
+	// -interfaces.exclude flags.
+	Interfaces *interfacesConfig `yaml:"interfaces"`
+	// Drivers overrides collector options per driver name.
+	Drivers map[string]driverConfig `yaml:"drivers"`
+	// Metrics filters the exported metrics by name.
+	Metrics metricsConfig `yaml:"metrics"`
+	// Labels are static labels added to every interface metric.
+	Labels map[string]string `yaml:"labels"`
+}
+
+type interfacesConfig struct {
+	Names   []string `yaml:"names"`
+	Include string   `yaml:"include"`
+	Exclude string   `yaml:"exclude"`
+}
+
+type driverConfig struct {
+	DriverStats        *bool  `yaml:"driver_stats"`
+	DriverStatsInclude string `yaml:"driver_stats_include"`
+	DriverStatsExclude string `yaml:"driver_stats_exclude"`
+	Transceiver        *bool  `yaml:"transceiver"`
+	Features           *bool  `yaml:"features"`
+}
+
+type metricsConfig struct {
+	Include string `yaml:"include"`
+	Exclude string `yaml:"exclude"`
+}
+
+// loadConfigFile reads a configuration file. Unknown fields are rejected.
+func loadConfigFile(path string) (*fileConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config fileConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &config, nil
+}
+
+// interfaceSelection chooses the interfaces to monitor: a fixed list of
+// names, or the auto-detected interfaces passing the include and exclude
+// regexps.
+type interfaceSelection struct {
+	names            []string
+	include, exclude string
+}
+
+// settings is the reloadable configuration, built from the flags and the
+// configuration file.
+type settings struct {
+	interfaces interfaceSelection
+	options    collector.Options
+}
+
+// loadSettings builds the settings from the flags and, with -config.file,
+// the configuration file, whose values take precedence.
+func loadSettings() (settings, error) {
+	s := settings{
+		interfaces: interfaceSelection{
+			names:   splitInterfaces(*interfaces),
+			include: *interfacesInclude,
+			exclude: *interfacesExclude,
+		},
+		options: collector.Options{
+			DriverStats: *driverStats,
+			Transceiver: *transceiverStats,
+			Features:    *featureStats,
+		},
+	}
+
+	var err error
+	if _, err = compileOptionalRegexp("interfaces.include", *interfacesInclude); err != nil {
+		return settings{}, err
+	}
+	if _, err = compileOptionalRegexp("interfaces.exclude", *interfacesExclude); err != nil {
+		return settings{}, err
+	}
+	opts := &s.options
+	if opts.DriverStatsInclude, err = compileOptionalRegexp("collector.driver-stats.include", *driverStatsInclude); err != nil {
+		return settings{}, err
+	}
+	if opts.DriverStatsExclude, err = compileOptionalRegexp("collector.driver-stats.exclude", *driverStatsExclude); err != nil {
+		return settings{}, err
+	}
+	if opts.FeaturesInclude, err = compileOptionalRegexp("collector.features.include", *featuresInclude); err != nil {
+		return settings{}, err
+	}
+	if opts.FeaturesExclude, err = compileOptionalRegexp("collector.features.exclude", *featuresExclude); err != nil {
+		return settings{}, err
+	}
+	if *coalesceExpected != "" {
+		if opts.ExpectedCoalesce, err = collector.LoadCoalesceExpectations(*coalesceExpected); err != nil {
+			return settings{}, fmt.Errorf("invalid coalesce expectations file: %v", err)
+		}
+	}
+
+	if *configFile == "" {
+		return s, nil
+	}
+	file, err := loadConfigFile(*configFile)
+	if err != nil {
+		return settings{}, err
+	}
+	if err := s.apply(file); err != nil {
+		return settings{}, fmt.Errorf("invalid config file %s: %v", *configFile, err)
+	}
+	return s, nil
+}
+
+// apply validates a configuration file and applies it over the settings.
+func (s *settings) apply(file *fileConfig) error {
+	if file.Interfaces != nil {
+		s.interfaces = interfaceSelection{
+			names:   file.Interfaces.Names,
+			include: file.Interfaces.Include,
+			exclude: file.Interfaces.Exclude,
+		}
+		if _, err := compileConfigRegexp("interfaces.include", s.interfaces.include); err != nil {
+			return err
+		}
+		if _, err := compileConfigRegexp("interfaces.exclude", s.interfaces.exclude); err != nil {
+			return err
+		}
+	}
+
+	if len(file.Drivers) > 0 {
+		s.options.Drivers = make(map[string]collector.DriverOptions, len(file.Drivers))
+	}
+	for driver, config := range file.Drivers {
+		options := collector.DriverOptions{
+			DriverStats: config.DriverStats,
+			Transceiver: config.Transceiver,
+			Features:    config.Features,
+		}
+		var err error
+		if options.DriverStatsInclude, err = compileConfigRegexp("drivers."+driver+".driver_stats_include", config.DriverStatsInclude); err != nil {
+			return err
+		}
+		if options.DriverStatsExclude, err = compileConfigRegexp("drivers."+driver+".driver_stats_exclude", config.DriverStatsExclude); err != nil {
+			return err
+		}
+		s.options.Drivers[driver] = options
+	}
+
+	var err error
+	if s.options.MetricsInclude, err = compileConfigRegexp("metrics.include", file.Metrics.Include); err != nil {
+		return err
+	}
+	if s.options.MetricsExclude, err = compileConfigRegexp("metrics.exclude", file.Metrics.Exclude); err != nil {
+		return err
+	}
+
+	for name := range file.Labels {
+		if !labelNameRE.MatchString(name) || strings.HasPrefix(name, "__") {
+			return fmt.Errorf("invalid label name %q", name)
+		}
+	}
+	s.options.Labels = file.Labels
+	return nil
+}
+
+// compileConfigRegexp compiles a regexp of the configuration file, returning
+// nil for an empty value.
+func compileConfigRegexp(field, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", field, err)
+	}
+	return re, nil
+}
+
+// splitInterfaces splits a comma-separated list of interface names.
+func splitInterfaces(list string) []string {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// newInterfaceSource returns the source of the selected interfaces. Listed
+// interfaces with unsupported drivers are skipped, and a list without any
+// supported interface is an error.
+func newInterfaceSource(selection interfaceSelection) (collector.InterfaceSource, error) {
+	if len(selection.names) > 0 {
+		eth, err := ethtool.NewEthtool()
+		if err != nil {
+			return nil, fmt.Errorf("failed to initialize ethtool: %v", err)
+		}
+		defer eth.Close()
+
+		var ifaceList []string
+		for _, iface := range selection.names {
+			info, err := eth.DriverInfo(iface)
+			if err != nil {
+				log.Warnf("Failed to get driver info for interface %s: %v", iface, err)
+				continue
+			}
+			if drivers.IsSupportedDriver(info.Driver) {
+				ifaceList = append(ifaceList, iface)
+				log.Debugf("Added manually specified interface %s with driver %s", iface, info.Driver)
+			} else {
+				log.Warnf("Skipping manually specified interface %s: unsupported driver %s", iface, info.Driver)
+			}
+		}
+
+		if len(ifaceList) == 0 {
+			return nil, fmt.Errorf("no supported network interfaces found (only %s drivers are supported)", drivers.SupportedDriversString())
+		}
+		return collector.StaticInterfaces(ifaceList), nil
+	}
+
+	var filter collector.InterfaceFilter
+	var err error
+	if filter.Include, err = compileConfigRegexp("interfaces.include", selection.include); err != nil {
+		return nil, err
+	}
+	if filter.Exclude, err = compileConfigRegexp("interfaces.exclude", selection.exclude); err != nil {
+		return nil, err
+	}
+
+	watcher, err := collector.NewInterfaceWatcher(filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to auto-detect network interfaces: %v", err)
+	}
+	if len(watcher.Interfaces()) == 0 {
+		log.Warnf("No supported network interfaces found yet (only %s drivers are supported); waiting for new interfaces", drivers.SupportedDriversString())
+	}
+	return watcher, nil
+}
+
+// reloader applies the settings to a running exporter on SIGHUP or a request
+// to /-/reload. A failed reload keeps the previous settings.
+type reloader struct {
+	mu         sync.Mutex
+	selection  interfaceSelection
+	interfaces *collector.ReloadableInterfaces
+	collector  *collector.EthtoolCollector
+
+	lastSuccessful  prometheus.Gauge
+	lastSuccessTime prometheus.Gauge
+}
+
+func newReloader(selection interfaceSelection, interfaces *collector.ReloadableInterfaces, c *collector.EthtoolCollector) *reloader {
+	r := &reloader{
+		selection:  selection,
+		interfaces: interfaces,
+		collector:  c,
+		lastSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "nic_exporter_config_last_reload_successful",
+			Help: "Whether the last configuration reload succeeded",
+		}),
+		lastSuccessTime: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "nic_exporter_config_last_reload_success_timestamp_seconds",
+			Help: "Timestamp of the last successful configuration reload",
+		}),
+	}
+	r.succeeded()
+	return r
+}
+
+// Describe implements prometheus.Collector.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.lastSuccessful.Describe(ch)
+	r.lastSuccessTime.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	r.lastSuccessful.Collect(ch)
+	r.lastSuccessTime.Collect(ch)
+}
+
+func (r *reloader) succeeded() {
+	r.lastSuccessful.Set(1)
+	r.lastSuccessTime.Set(float64(time.Now().Unix()))
+}
+
+// reload reloads the settings. The interface source is only rebuilt when the
+// interface selection changed.
+func (r *reloader) reload() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	err := r.apply()
+	if err != nil {
+		log.Errorf("Failed to reload configuration: %v", err)
+		r.lastSuccessful.Set(0)
+		return err
+	}
+	log.Info("Reloaded configuration")
+	r.succeeded()
+	return nil
+}
+
+func (r *reloader) apply() error {
+	s, err := loadSettings()
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(s.interfaces, r.selection) {
+		source, err := newInterfaceSource(s.interfaces)
+		if err != nil {
+			return err
+		}
+		r.interfaces.Set(source)
+		r.selection = s.interfaces
+		log.Infof("Monitoring supported interfaces (%s): %s", drivers.SupportedDriversString(), strings.Join(r.interfaces.Interfaces(), ", "))
+	}
+	r.collector.SetOptions(s.options)
+	return nil
+}
+
+// ServeHTTP reloads the settings on POST or PUT requests.
+func (r *reloader) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost && req.Method != http.MethodPut {
+		w.Header().Set("Allow", "POST, PUT")
+		http.Error(w, "Only POST or PUT requests allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := r.reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Failed to reload configuration: %v", err), http.StatusInternalServerError)
+	}
+}
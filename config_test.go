@@ -0,0 +1,222 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+// writeConfigFile writes a configuration file and points -config.file at it
+// for the duration of the test.
+func writeConfigFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	previous := *configFile
+	*configFile = path
+	t.Cleanup(func() { *configFile = previous })
+	return path
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    *fileConfig
+		wantErr string
+	}{
+		{name: "empty", content: "", want: &fileConfig{}},
+		{
+			name: "all sections",
+			content: `
+interfaces:
+  names: [eth0, eth1]
+drivers:
+  ice:
+    driver_stats: true
+    driver_stats_include: 'fec'
+metrics:
+  exclude: '^nic_feature_'
+labels:
+  site: ams1
+`,
+			want: &fileConfig{
+				Interfaces: &interfacesConfig{Names: []string{"eth0", "eth1"}},
+				Drivers: map[string]driverConfig{
+					"ice": {DriverStats: boolPtr(true), DriverStatsInclude: "fec"},
+				},
+				Metrics: metricsConfig{Exclude: "^nic_feature_"},
+				Labels:  map[string]string{"site": "ams1"},
+			},
+		},
+		{
+			name:    "unknown field",
+			content: "metrics:\n  drop: '^nic_'\n",
+			wantErr: "field drop not found",
+		},
+		{
+			name:    "invalid YAML",
+			content: "labels: [site",
+			wantErr: "failed to parse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := loadConfigFile(writeConfigFile(t, tt.content))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadConfigFile() = %v, want error containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadConfigFile() = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("loadConfigFile() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSettingsApply(t *testing.T) {
+	s := settings{interfaces: interfaceSelection{include: "^eth"}}
+	s.options.DriverStats = true
+	err := s.apply(&fileConfig{
+		Interfaces: &interfacesConfig{Exclude: "_rep$"},
+		Drivers: map[string]driverConfig{
+			"mlx5_core": {Transceiver: boolPtr(true), DriverStatsExclude: "^rx_vport_"},
+		},
+		Metrics: metricsConfig{Include: "^nic_(rx|tx)_"},
+		Labels:  map[string]string{"site": "ams1"},
+	})
+	if err != nil {
+		t.Fatalf("apply() = %v", err)
+	}
+
+	// The interface section replaces the selection as a whole.
+	if want := (interfaceSelection{exclude: "_rep$"}); !reflect.DeepEqual(s.interfaces, want) {
+		t.Errorf("interfaces = %+v, want %+v", s.interfaces, want)
+	}
+	if !s.options.DriverStats {
+		t.Error("driver stats flag value not kept")
+	}
+	mlx5, ok := s.options.Drivers["mlx5_core"]
+	if !ok || mlx5.Transceiver == nil || !*mlx5.Transceiver || mlx5.DriverStats != nil {
+		t.Errorf("mlx5_core options = %+v", mlx5)
+	}
+	if mlx5.DriverStatsExclude == nil || !mlx5.DriverStatsExclude.MatchString("rx_vport_unicast_bytes") {
+		t.Errorf("mlx5_core driver_stats_exclude = %v", mlx5.DriverStatsExclude)
+	}
+	if s.options.MetricsInclude == nil || s.options.MetricsInclude.String() != "^nic_(rx|tx)_" {
+		t.Errorf("metrics include = %v", s.options.MetricsInclude)
+	}
+	if s.options.MetricsExclude != nil {
+		t.Errorf("metrics exclude = %v, want none", s.options.MetricsExclude)
+	}
+	if !reflect.DeepEqual(s.options.Labels, map[string]string{"site": "ams1"}) {
+		t.Errorf("labels = %v", s.options.Labels)
+	}
+}
+
+func TestSettingsApplyErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		file    fileConfig
+		wantErr string
+	}{
+		{
+			name:    "interface regexp",
+			file:    fileConfig{Interfaces: &interfacesConfig{Include: "eth("}},
+			wantErr: "invalid interfaces.include",
+		},
+		{
+			name:    "driver regexp",
+			file:    fileConfig{Drivers: map[string]driverConfig{"ice": {DriverStatsInclude: "["}}},
+			wantErr: "invalid drivers.ice.driver_stats_include",
+		},
+		{
+			name:    "metric regexp",
+			file:    fileConfig{Metrics: metricsConfig{Exclude: "*"}},
+			wantErr: "invalid metrics.exclude",
+		},
+		{
+			name:    "label name",
+			file:    fileConfig{Labels: map[string]string{"data-center": "ams"}},
+			wantErr: `invalid label name "data-center"`,
+		},
+		{
+			name:    "reserved label name",
+			file:    fileConfig{Labels: map[string]string{"__name__": "x"}},
+			wantErr: `invalid label name "__name__"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s settings
+			err := s.apply(&tt.file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("apply() = %v, want error containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadSettingsReload(t *testing.T) {
+	path := writeConfigFile(t, "labels:\n  site: ams1\n")
+	s, err := loadSettings()
+	if err != nil {
+		t.Fatalf("loadSettings() = %v", err)
+	}
+	if s.options.Labels["site"] != "ams1" {
+		t.Errorf("labels = %v, want site=ams1", s.options.Labels)
+	}
+
+	// A reload sees the changed file.
+	if err := os.WriteFile(path, []byte("labels:\n  site: fra1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if s, err = loadSettings(); err != nil {
+		t.Fatalf("loadSettings() after change = %v", err)
+	}
+	if s.options.Labels["site"] != "fra1" {
+		t.Errorf("labels after change = %v, want site=fra1", s.options.Labels)
+	}
+
+	// An invalid file fails the reload, leaving the running settings to the
+	// caller.
+	if err := os.WriteFile(path, []byte("labels:\n  bad-name: x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadSettings(); err == nil || !strings.Contains(err.Error(), "invalid config file") {
+		t.Errorf("loadSettings() with invalid file = %v, want an invalid config file error", err)
+	}
+}
+
+func TestReloaderReloadFailure(t *testing.T) {
+	writeConfigFile(t, "unknown: true\n")
+	r := newReloader(interfaceSelection{}, nil, nil)
+
+	if err := r.reload(); err == nil {
+		t.Fatal("reload() of an invalid file succeeded")
+	}
+	var m dto.Metric
+	if err := r.lastSuccessful.Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	if got := m.GetGauge().GetValue(); got != 0 {
+		t.Errorf("nic_exporter_config_last_reload_successful = %v, want 0", got)
+	}
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
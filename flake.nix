@@ -18,7 +18,7 @@
             version = "0.1.0";
             src = ./.;
 
-            vendorHash = "sha256-5wf8OslCSUem7q1M43pqILqbYqo8mNnqwjywB1OS9PI=";
+            vendorHash = "sha256-eNJNLCtO9z3fyzoNcVHZujUNm7VIcXTZ4EPAfMueGKY=";
 
             meta = with pkgs.lib; {
               description = "Prometheus exporter for ethtool metrics";
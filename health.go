@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/minhu/prometheus-ethtool-exporter/collector"
+)
+
+// healthy answers /-/healthy: the exporter is healthy as long as it serves
+// requests.
+func healthy(w http.ResponseWriter, r *http.Request) {
+	if _, err := fmt.Fprintln(w, "Healthy"); err != nil {
+		log.Errorf("Error writing response: %v", err)
+	}
+}
+
+// readiness answers /-/ready: the exporter is ready while the collector can
+// collect at least one interface and it is not shutting down.
+type readiness struct {
+	collector    *collector.EthtoolCollector
+	shuttingDown atomic.Bool
+}
+
+func (r *readiness) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if r.shuttingDown.Load() {
+		http.Error(w, "Shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if err := r.collector.Ready(); err != nil {
+		http.Error(w, fmt.Sprintf("Not ready: %v", err), http.StatusServiceUnavailable)
+		return
+	}
+	if _, err := fmt.Fprintln(w, "Ready"); err != nil {
+		log.Errorf("Error writing response: %v", err)
+	}
+}
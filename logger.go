@@ -0,0 +1,33 @@
+package main
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// toolkitLogger forwards the key-value logs of the exporter toolkit, which
+// uses go-kit loggers, to logrus.
+type toolkitLogger struct{}
+
+// Log implements the go-kit log.Logger interface.
+func (toolkitLogger) Log(keyvals ...interface{}) error {
+	level := log.InfoLevel
+	msg := ""
+	fields := log.Fields{}
+	for i := 0; i+1 < len(keyvals); i += 2 {
+		key := fmt.Sprint(keyvals[i])
+		switch key {
+		case "msg":
+			msg = fmt.Sprint(keyvals[i+1])
+		case "level":
+			if l, err := log.ParseLevel(fmt.Sprint(keyvals[i+1])); err == nil {
+				level = l
+			}
+		default:
+			fields[key] = keyvals[i+1]
+		}
+	}
+	log.WithFields(fields).Log(level, msg)
+	return nil
+}
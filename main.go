@@ -1,64 +1,71 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/minhu/prometheus-ethtool-exporter/collector"
 	"github.com/minhu/prometheus-ethtool-exporter/collector/drivers"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
-	"github.com/safchain/ethtool"
+	"github.com/prometheus/exporter-toolkit/web"
 	log "github.com/sirupsen/logrus"
-	"github.com/vishvananda/netlink"
 )
 
 var (
 	listenAddress = flag.String("web.listen-address", ":9417", "Address on which to expose metrics")
 	metricsPath   = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics")
+	webConfigFile = flag.String("web.config.file", "", "Exporter toolkit web configuration file enabling TLS and/or basic authentication")
+	shutdownGrace = flag.Duration("web.shutdown-timeout", 10*time.Second, "Maximum time to wait for in-flight requests when shutting down")
 	interfaces    = flag.String("interfaces", "", "Comma-separated list of interfaces to monitor (default: all interfaces)")
+
+	interfacesInclude = flag.String("interfaces.include", "", "Regexp of interface names to monitor when auto-detecting interfaces")
+	interfacesExclude = flag.String("interfaces.exclude", "", "Regexp of interface names to skip when auto-detecting interfaces")
+
+	backend            = flag.String("collector.backend", collector.BackendAuto, "Statistics backend: auto (netlink where supported, else ioctl), netlink or ioctl")
+	workers            = flag.Int("collector.workers", 4, "Number of interfaces collected in parallel")
+	interfaceTimeout   = flag.Duration("collector.interface-timeout", 5*time.Second, "Maximum time to collect the metrics of a single interface (0 disables)")
+	cacheMaxAge        = flag.Duration("collector.cache-max-age", 0, "Maximum age of cached ethtool statistics shared by close-together scrapes (0 disables)")
+	monotonicCounters  = flag.Bool("collector.monotonic-counters", false, "Compensate ethtool counter resets and 32-bit wraps so that exported counters never decrease")
+	driverStats        = flag.Bool("collector.driver-stats", false, "Export raw driver-specific ethtool counters as nic_<driver>_<counter> metrics")
+	driverStatsInclude = flag.String("collector.driver-stats.include", "", "Regexp of raw ethtool counter names to export (default: all)")
+	driverStatsExclude = flag.String("collector.driver-stats.exclude", "", "Regexp of raw ethtool counter names to skip")
+	transceiverStats   = flag.Bool("collector.transceiver", false, "Export optical transceiver (SFP/QSFP) diagnostics read from the module EEPROM")
+	featureStats       = flag.Bool("collector.features", true, "Export offload feature states as nic_feature_* metrics")
+	featuresInclude    = flag.String("collector.features.include", collector.DefaultFeaturesInclude, "Regexp of offload feature names to export (empty: all)")
+	featuresExclude    = flag.String("collector.features.exclude", "", "Regexp of offload feature names to skip")
+	sampleInterval     = flag.Duration("collector.sample-interval", 0, "Interval of background counter sampling for rate and utilization metrics (0 disables)")
+	sampleWindow       = flag.Duration("collector.sample-window", 15*time.Second, "Window over which sampled rates and peak utilization are computed")
+	microburstInterval = flag.Duration("collector.microburst.interval", 0, "Interval of high-frequency counter sampling for microburst metrics, at least 10ms (0 disables)")
+	microburstWindow   = flag.Duration("collector.microburst.window", 15*time.Second, "Window over which the highest microburst rates are reported")
+	microburstCounters = flag.String("collector.microburst.counters", collector.DefaultMicroburstCounters, "Regexp of raw ethtool counter names read by the microburst sampler")
+	coalesceExpected   = flag.String("collector.coalesce.expected-file", "", "YAML file with expected interrupt coalescing parameters reported as nic_config_drift")
+
+	genericFallback = flag.Bool("driver.generic-fallback", false, "Monitor interfaces with unsupported drivers using best-effort generic counter mapping")
+	mappingFile     = flag.String("driver.mapping-file", "", "YAML or JSON file with counter mapping definitions merged over the built-in driver mappings")
+
+	configFile = flag.String("config.file", "", "YAML file with interface selection, per-driver options, metric filters and static labels, reloaded on SIGHUP or POST /-/reload")
 )
 
-// getNetworkInterfaces returns a list of all available network interfaces with supported drivers
-func getNetworkInterfaces() ([]string, error) {
-	links, err := netlink.LinkList()
-	if err != nil {
-		return nil, err
+// compileOptionalRegexp compiles a regexp flag value, returning nil for an empty value.
+func compileOptionalRegexp(name, expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
 	}
-
-	// Initialize ethtool
-	eth, err := ethtool.NewEthtool()
+	re, err := regexp.Compile(expr)
 	if err != nil {
-		return nil, err
-	}
-	defer eth.Close()
-
-	var interfaces []string
-	for _, link := range links {
-		// Skip loopback
-		if link.Attrs().Name == "lo" {
-			continue
-		}
-
-		// Get driver info
-		info, err := eth.DriverInfo(link.Attrs().Name)
-		if err != nil {
-			log.Debugf("Failed to get driver info for interface %s: %v", link.Attrs().Name, err)
-			continue
-		}
-
-		// Only include supported interfaces
-		if drivers.IsSupportedDriver(info.Driver) {
-			interfaces = append(interfaces, link.Attrs().Name)
-			log.Debugf("Found supported interface %s with driver %s", link.Attrs().Name, info.Driver)
-		} else {
-			log.Debugf("Skipping unsupported interface %s with driver %s", link.Attrs().Name, info.Driver)
-		}
+		return nil, fmt.Errorf("invalid -%s: %v", name, err)
 	}
-	return interfaces, nil
+	return re, nil
 }
 
 func main() {
@@ -69,61 +76,90 @@ func main() {
 		FullTimestamp: true,
 	})
 
+	drivers.SetGenericFallback(*genericFallback)
+	if *mappingFile != "" {
+		if err := drivers.LoadMappingFile(*mappingFile); err != nil {
+			log.Fatalf("Invalid driver mapping file: %v", err)
+		}
+		log.Infof("Loaded driver mapping file %s", *mappingFile)
+	}
+
+	// Fail early on an invalid web configuration rather than on the first
+	// connection
+	if err := web.Validate(*webConfigFile); err != nil {
+		log.Fatalf("Invalid web configuration file: %v", err)
+	}
+
 	// Check if running as root
 	if os.Geteuid() != 0 {
 		log.Warn("Running as non-root; make sure CAP_NET_ADMIN and CAP_NET_RAW are available")
 	}
 
-	// Parse interfaces
-	var ifaceList []string
-	if *interfaces != "" {
-		// When interfaces are manually specified, we'll still filter them
-		eth, err := ethtool.NewEthtool()
-		if err != nil {
-			log.Fatalf("Failed to initialize ethtool: %v", err)
-		}
-		defer eth.Close()
-
-		for _, iface := range strings.Split(*interfaces, ",") {
-			iface = strings.TrimSpace(iface)
-			if iface == "" {
-				continue
-			}
-			info, err := eth.DriverInfo(iface)
-			if err != nil {
-				log.Warnf("Failed to get driver info for interface %s: %v", iface, err)
-				continue
-			}
-			if drivers.IsSupportedDriver(info.Driver) {
-				ifaceList = append(ifaceList, iface)
-				log.Debugf("Added manually specified interface %s with driver %s", iface, info.Driver)
-			} else {
-				log.Warnf("Skipping manually specified interface %s: unsupported driver %s", iface, info.Driver)
-			}
-		}
-	} else {
-		var err error
-		ifaceList, err = getNetworkInterfaces()
-		if err != nil {
-			log.Fatalf("Failed to auto-detect network interfaces: %v", err)
-		}
+	// Build the reloadable settings from the flags and the config file
+	settings, err := loadSettings()
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	if len(ifaceList) == 0 {
-		log.Fatalf("No supported network interfaces found (only %s drivers are supported)", drivers.SupportedDriversString())
+	initial, err := newInterfaceSource(settings.interfaces)
+	if err != nil {
+		log.Fatal(err)
+	}
+	source := collector.NewReloadableInterfaces(initial)
+	defer source.Close()
+
+	// Build collector configuration
+	config := collector.Config{
+		Backend:     *backend,
+		Workers:     *workers,
+		Timeout:     *interfaceTimeout,
+		CacheMaxAge: *cacheMaxAge,
+
+		MonotonicCounters: *monotonicCounters,
+
+		Options: settings.options,
+
+		SampleInterval: *sampleInterval,
+		SampleWindow:   *sampleWindow,
+
+		MicroburstInterval: *microburstInterval,
+		MicroburstWindow:   *microburstWindow,
+	}
+	if config.MicroburstCounters, err = compileOptionalRegexp("collector.microburst.counters", *microburstCounters); err != nil {
+		log.Fatal(err)
 	}
 
 	// Create and register collector
-	collector, err := collector.NewEthtoolCollector(ifaceList)
+	collector, err := collector.NewEthtoolCollector(source, config)
 	if err != nil {
 		log.Fatalf("Failed to create collector: %v", err)
 	}
-	defer collector.Close()
+	defer func() {
+		if err := collector.Close(); err != nil {
+			log.Errorf("Error closing collector: %v", err)
+		}
+	}()
+
+	configReloader := newReloader(settings.interfaces, source, collector)
+	prometheus.MustRegister(configReloader)
+
+	// Reload the configuration on SIGHUP
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			configReloader.reload()
+		}
+	}()
 
 	prometheus.MustRegister(collector)
 
 	// Setup HTTP server
 	http.Handle(*metricsPath, promhttp.Handler())
+	http.Handle("/-/reload", configReloader)
+	http.HandleFunc("/-/healthy", healthy)
+	ready := &readiness{collector: collector}
+	http.Handle("/-/ready", ready)
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if _, err := w.Write([]byte(`<html>
 			<head><title>Network Interface Statistics Exporter</title></head>
@@ -139,7 +175,7 @@ func main() {
 
 	// Start server
 	log.Infof("Starting network interface statistics exporter on %s", *listenAddress)
-	log.Infof("Monitoring supported interfaces (%s): %s", drivers.SupportedDriversString(), strings.Join(ifaceList, ", "))
+	log.Infof("Monitoring supported interfaces (%s): %s", drivers.SupportedDriversString(), strings.Join(source.Interfaces(), ", "))
 	srv := &http.Server{
 		Addr:         *listenAddress,
 		Handler:      nil, // Use default handler
@@ -147,7 +183,34 @@ func main() {
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  15 * time.Second,
 	}
-	if err := srv.ListenAndServe(); err != nil {
+	webFlags := &web.FlagConfig{
+		WebListenAddresses: &[]string{*listenAddress},
+		WebSystemdSocket:   new(bool),
+		WebConfigFile:      webConfigFile,
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- web.ListenAndServe(srv, webFlags, toolkitLogger{})
+	}()
+
+	// Shut down gracefully on SIGINT or SIGTERM, letting in-flight scrapes
+	// finish before the collector is closed by the deferred calls
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	select {
+	case err := <-serveErr:
 		log.Fatalf("Error starting HTTP server: %s", err)
+	case sig := <-stop:
+		log.Infof("Received %s, shutting down", sig)
+	}
+
+	ready.shuttingDown.Store(true)
+	ctx, cancel := context.WithTimeout(context.Background(), *shutdownGrace)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		log.Errorf("Error shutting down HTTP server: %v", err)
+	}
+	if err := <-serveErr; err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf("Error from HTTP server: %v", err)
 	}
 }